@@ -0,0 +1,138 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Accelerator arrow length without the arrow head
+const acceleratorLength = 50
+
+// Accelerator arrow head length
+const acceleratorHead = 18
+
+// Distance between a node and its attached accelerator
+const acceleratorGap = 15
+
+// acceleratorMarkers - Defines the accelerator and de-accelerator arrow heads.
+// De-accelerators have a bar in front of the arrow head.
+func acceleratorMarkers(theme *hcl.Theme) {
+	canvas.Marker("accelerator", 2, 12, acceleratorHead, 24, `orient="auto"`, `markerUnits="userSpaceOnUse"`)
+	canvas.Path("M0,0 L0,24 L18,12 z", styleAttrs("wm-marker", "fill:"+theme.ConnectorColor, "")...)
+	canvas.MarkerEnd()
+	canvas.Marker("deaccelerator", 2, 12, acceleratorHead+8, 24, `orient="auto"`, `markerUnits="userSpaceOnUse"`)
+	canvas.Path("M0,0 L0,24 L18,12 z M22,0 L26,0 L26,24 L22,24 z", styleAttrs("wm-marker", "fill:"+theme.ConnectorColor, "")...)
+	canvas.MarkerEnd()
+}
+
+// acceleratorSpan - Returns the start and end x of the accelerator line.
+// Attached accelerators start next to their node, the others are centred on their position.
+func acceleratorSpan(a *hcl.Accelerator) (int, int) {
+	sign := 1
+	if a.Direction == "backward" {
+		sign = -1
+	}
+	x0 := a.X - sign*acceleratorLength/2
+	if a.Node != "" {
+		x0 = a.X + sign*acceleratorGap
+	}
+	return x0, x0 + sign*acceleratorLength
+}
+
+// acceleratorArrow - Accelerator arrow head and de-accelerator bar in map coordinates, as drawn by acceleratorMarkers.
+type acceleratorArrow struct {
+	// x0, x1 - Start and end of the line, the head starts 2px before the end.
+	x0, x1 int
+	// tip - End of the arrow head.
+	tip  int
+	head []hcl.Point
+	// bar - Bar in front of the arrow head, only for de-accelerators.
+	bar *box
+}
+
+// acceleratorShape - Returns the accelerator arrow, for formats that draw the arrow head as a shape.
+func acceleratorShape(a *hcl.Accelerator) acceleratorArrow {
+	x0, x1 := acceleratorSpan(a)
+	sign := 1
+	if x1 < x0 {
+		sign = -1
+	}
+	base := x1 - 2*sign
+	arrow := acceleratorArrow{
+		x0:   x0,
+		x1:   x1,
+		tip:  base + acceleratorHead*sign,
+		head: []hcl.Point{{X: base, Y: a.Y - 12}, {X: base, Y: a.Y + 12}, {X: base + acceleratorHead*sign, Y: a.Y}},
+	}
+	if a.Direction == "backward" {
+		bar := box{x0: minInt(base+22*sign, base+26*sign), y0: a.Y - 12, x1: maxInt(base+22*sign, base+26*sign), y1: a.Y + 12}
+		arrow.bar = &bar
+	}
+	return arrow
+}
+
+// acceleratorLabel - Returns the first baseline of the accelerator label, centred above the arrow.
+func acceleratorLabel(a *hcl.Accelerator, font *hcl.Font) (int, int, []string) {
+	x0, x1 := acceleratorSpan(a)
+	lines := strings.Split(a.Label, "\n")
+	return (x0 + x1) / 2, a.Y - 10 - (len(lines)-1)*(font.Size+3), lines
+}
+
+// placeAccelerators - Sets the accelerator positions and returns the area they cover.
+func placeAccelerators(m *hcl.Map, nodes map[string]*hcl.Node, maxX []int, maxY int) []box {
+	boxes := []box{}
+	for _, a := range m.Accelerators {
+		if n, ok := nodes[a.Node]; ok {
+			a.X, a.Y = n.X, n.Y
+		} else {
+			n := &hcl.Node{Stage: a.Stage, EvolutionX: a.EvolutionX, Visibility: a.Visibility}
+			NodeXY(n, maxX, maxY)
+			a.X, a.Y = n.X, n.Y
+		}
+		x0, x1 := acceleratorSpan(a)
+		if x0 > x1 {
+			x0, x1 = x1-acceleratorHead-8, x0
+		} else {
+			x1 += acceleratorHead + 8
+		}
+		boxes = append(boxes, box{x0: x0, y0: a.Y - 14, x1: x1, y1: a.Y + 14})
+	}
+	return boxes
+}
+
+// drawAccelerator - Draws an accelerator arrow from x0 to x1.
+func drawAccelerator(x0, x1, y int, direction string, theme *hcl.Theme) {
+	marker := "accelerator"
+	if direction == "backward" {
+		marker = "deaccelerator"
+	}
+	canvas.Line(x0, y, x1, y, styleAttrs("wm-accelerator__arrow wm-accelerator__arrow--"+direction,
+		fmt.Sprintf("stroke:%s;stroke-width:10;opacity:0.6;marker-end:url(#%s)", theme.ConnectorColor, marker), "")...)
+}
+
+// drawAccelerators - Draws the accelerators with their label above the arrow.
+func drawAccelerators(m *hcl.Map) {
+	for _, a := range m.Accelerators {
+		x0, x1 := acceleratorSpan(a)
+		canvas.Group(styleAttrs("wm-accelerator wm-accelerator--"+a.Direction, halo(m.Theme.Halo), "")...)
+		if a.Label != "" {
+			canvas.Title(a.Label)
+		}
+		drawAccelerator(x0, x1, a.Y, a.Direction, m.Theme)
+		if a.Label != "" {
+			x, y, lines := acceleratorLabel(a, m.Font.Node)
+			textlines(canvas, x, y, lines, m.Font.Node, m.Theme.Text, "middle", "wm-accelerator__label")
+		}
+		canvas.Gend()
+	}
+}
@@ -0,0 +1,118 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// dotShapes - Graphviz node shape for each node type.
+var dotShapes = map[string]string{
+	"component": "ellipse",
+	"anchor":    "plaintext",
+	"market":    "doublecircle",
+	"ecosystem": "doubleoctagon",
+}
+
+// dotEdgeStyles - Graphviz edge attributes for each connector type.
+// Change connectors don't constrain the ranks, they show evolution rather than a dependency.
+var dotEdgeStyles = map[string]string{
+	"normal":         "",
+	"bold":           "penwidth=2",
+	"change":         "style=dashed, constraint=false",
+	"change-inertia": "style=dashed, arrowhead=teenormal, constraint=false",
+}
+
+// dotQuote - Returns s as a DOT quoted string, newlines become line breaks.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// dotAttrs - Returns the DOT attribute list, skipping empty values.
+func dotAttrs(attrs ...string) string {
+	list := []string{}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if attrs[i+1] != "" {
+			list = append(list, attrs[i]+"="+dotQuote(attrs[i+1]))
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// graphConnectors - Returns the connectors with both nodes in the map.
+// Connectors to unknown nodes are reported and skipped, as in the svg, instead of exporting edges without a node.
+func graphConnectors(m *hcl.Map) []*hcl.Connector {
+	nodes := map[string]bool{}
+	for _, n := range m.Nodes {
+		nodes[n.ID] = true
+	}
+	connectors := []*hcl.Connector{}
+	for _, c := range m.Connectors {
+		for _, id := range []string{c.From, c.To} {
+			if !nodes[id] {
+				fmt.Fprintf(os.Stderr, "ERROR: couldn't find node '%s'\n", id)
+			}
+		}
+		if nodes[c.From] && nodes[c.To] {
+			connectors = append(connectors, c)
+		}
+	}
+	return connectors
+}
+
+// dotExport - Writes the nodes and connectors as a Graphviz DOT directed graph.
+// Connectors go from the dependent node to its dependency, so the value chain reads from the top down.
+func dotExport(w io.Writer, m *hcl.Map, pg page) error {
+	fmt.Fprintf(w, "digraph %s {\n", dotQuote(mapTitle(pg.file, m)))
+	fmt.Fprintf(w, "  graph [%s, rankdir=TB];\n", dotAttrs("bgcolor", m.Theme.Background, "fontcolor", m.Theme.Text, "fontname", m.Font.Family))
+	if m.Meta != nil && m.Meta.Title != "" {
+		fmt.Fprintf(w, "  label=%s;\n  labelloc=t;\n", dotQuote(m.Meta.Title))
+	}
+	fmt.Fprintf(w, "  node [%s, style=filled];\n", dotAttrs("fillcolor", m.Theme.NodeFill, "color", m.Theme.NodeColor, "fontcolor", m.Theme.Text, "fontname", m.Font.Node.Family))
+	fmt.Fprintf(w, "  edge [%s];\n", dotAttrs("color", m.Theme.ConnectorColor, "fontcolor", m.Theme.Text, "fontname", m.Font.Connector.Family))
+	for _, n := range m.Nodes {
+		class := "wm-node wm-node--" + classID(n.Evolution)
+		if n.Type != "component" {
+			class += " wm-node--" + n.Type
+		}
+		attrs := dotAttrs(
+			"label", n.Label,
+			"fillcolor", n.Fill,
+			"color", n.Color,
+			"tooltip", n.Description,
+			"URL", n.URL,
+			"class", class,
+		)
+		attrs += ", shape=" + dotShapes[n.Type]
+		if n.IsAnchor() {
+			attrs += ", style=bold"
+		}
+		fmt.Fprintf(w, "  %s [%s];\n", dotQuote(n.ID), attrs)
+	}
+	for _, c := range graphConnectors(m) {
+		attrs := dotAttrs(
+			"label", c.Label,
+			"color", c.Color,
+			"URL", c.URL,
+			"class", "wm-connector wm-connector--"+classID(c.Type),
+		)
+		if style := dotEdgeStyles[c.Type]; style != "" {
+			attrs += ", " + style
+		}
+		fmt.Fprintf(w, "  %s -> %s [%s];\n", dotQuote(c.From), dotQuote(c.To), attrs)
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
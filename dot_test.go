@@ -0,0 +1,34 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDotExport(t *testing.T) {
+	m, pg := testMap(t, "unknown", unknownNodeMap)
+	buf := new(bytes.Buffer)
+	err := dotExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "ghost") {
+		t.Errorf("expected the connector to an unknown node to be skipped:\n%s", out)
+	}
+	if !strings.Contains(out, `  "a" -> "b" [`) {
+		t.Errorf("expected the a -> b edge:\n%s", out)
+	}
+	if !strings.HasPrefix(out, `digraph "unknown" {`) || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("expected a closed digraph:\n%s", out)
+	}
+}
@@ -0,0 +1,392 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+type drawioFile struct {
+	XMLName xml.Name      `xml:"mxfile"`
+	Host    string        `xml:"host,attr"`
+	Diagram drawioDiagram `xml:"diagram"`
+}
+
+type drawioDiagram struct {
+	ID    string      `xml:"id,attr"`
+	Name  string      `xml:"name,attr"`
+	Model drawioModel `xml:"mxGraphModel"`
+}
+
+type drawioModel struct {
+	Grid       int           `xml:"grid,attr"`
+	Page       int           `xml:"page,attr"`
+	PageWidth  int           `xml:"pageWidth,attr"`
+	PageHeight int           `xml:"pageHeight,attr"`
+	Background string        `xml:"background,attr"`
+	Cells      []interface{} `xml:"root>mxCell"`
+}
+
+// drawioCell - Shape, text or line of the diagram.
+type drawioCell struct {
+	XMLName  xml.Name        `xml:"mxCell"`
+	ID       string          `xml:"id,attr,omitempty"`
+	Value    string          `xml:"value,attr,omitempty"`
+	Style    string          `xml:"style,attr,omitempty"`
+	Vertex   string          `xml:"vertex,attr,omitempty"`
+	Edge     string          `xml:"edge,attr,omitempty"`
+	Parent   string          `xml:"parent,attr,omitempty"`
+	Source   string          `xml:"source,attr,omitempty"`
+	Target   string          `xml:"target,attr,omitempty"`
+	Geometry *drawioGeometry `xml:"mxGeometry"`
+}
+
+// drawioObject - Cell with a link or a tooltip.
+type drawioObject struct {
+	XMLName xml.Name   `xml:"UserObject"`
+	ID      string     `xml:"id,attr"`
+	Label   string     `xml:"label,attr"`
+	Link    string     `xml:"link,attr,omitempty"`
+	Tooltip string     `xml:"tooltip,attr,omitempty"`
+	Cell    drawioCell `xml:"mxCell"`
+}
+
+type drawioGeometry struct {
+	X        int             `xml:"x,attr,omitempty"`
+	Y        int             `xml:"y,attr,omitempty"`
+	Width    int             `xml:"width,attr,omitempty"`
+	Height   int             `xml:"height,attr,omitempty"`
+	Relative string          `xml:"relative,attr,omitempty"`
+	As       string          `xml:"as,attr"`
+	Points   []drawioPoint   `xml:"mxPoint"`
+	Waypoint *drawioWaypoint `xml:"Array"`
+}
+
+type drawioPoint struct {
+	X  int    `xml:"x,attr"`
+	Y  int    `xml:"y,attr"`
+	As string `xml:"as,attr,omitempty"`
+}
+
+type drawioWaypoint struct {
+	As     string        `xml:"as,attr"`
+	Points []drawioPoint `xml:"mxPoint"`
+}
+
+// drawioDiagramBuilder - Collects the cells in drawing order, in absolute drawing coordinates.
+type drawioDiagramBuilder struct {
+	mapFrame
+	cells []interface{}
+}
+
+func (d *drawioDiagramBuilder) add(cell drawioCell) {
+	cell.Parent = "1"
+	d.cells = append(d.cells, cell)
+}
+
+// addObject - Adds the cell wrapped in an object when it has a link or a tooltip.
+func (d *drawioDiagramBuilder) addObject(cell drawioCell, link, tooltip string) {
+	if link == "" && tooltip == "" {
+		d.add(cell)
+		return
+	}
+	o := drawioObject{ID: cell.ID, Label: cell.Value, Link: link, Tooltip: tooltip, Cell: cell}
+	o.Cell.ID, o.Cell.Value, o.Cell.Parent = "", "", "1"
+	d.cells = append(d.cells, o)
+}
+
+// vertex - Returns a shape covering the box, given in absolute coordinates.
+func (d *drawioDiagramBuilder) vertex(id, value, style string, b box) drawioCell {
+	return drawioCell{ID: id, Value: value, Style: style, Vertex: "1",
+		Geometry: &drawioGeometry{X: b.x0, Y: b.y0, Width: b.x1 - b.x0, Height: b.y1 - b.y0, As: "geometry"}}
+}
+
+// line - Adds a line between two points given in absolute coordinates.
+func (d *drawioDiagramBuilder) line(id, style string, x0, y0, x1, y1 int) {
+	d.add(drawioCell{ID: id, Style: style, Edge: "1", Geometry: &drawioGeometry{Relative: "1", As: "geometry", Points: []drawioPoint{
+		{X: x0, Y: y0, As: "sourcePoint"},
+		{X: x1, Y: y1, As: "targetPoint"},
+	}}})
+}
+
+// text - Adds multi line text with its first baseline at x, y in absolute coordinates.
+func (d *drawioDiagramBuilder) text(id string, x, y int, lines []string, font *hcl.Font, color, anchor string, rotation int) {
+	b := textBox(x, y, lines, font, anchor)
+	d.add(d.vertex(id, strings.Join(lines, "\n"), drawioTextStyle(font, color, drawioAlign(anchor), rotation), b))
+}
+
+// drawioAlign - Returns the text alignment for the svg text anchor.
+func drawioAlign(anchor string) string {
+	switch anchor {
+	case "middle":
+		return "center"
+	case "end":
+		return "right"
+	}
+	return "left"
+}
+
+func drawioTextStyle(font *hcl.Font, color, align string, rotation int) string {
+	style := 0
+	if font.Weight == "bold" {
+		style = 1
+	}
+	s := fmt.Sprintf("text;html=0;whiteSpace=nowrap;spacing=0;align=%s;verticalAlign=top;fontFamily=%s;fontSize=%d;fontStyle=%d;fontColor=%s;",
+		align, font.Family, font.Size, style, drawioColor(color))
+	if rotation != 0 {
+		s += fmt.Sprintf("rotation=%d;", rotation)
+	}
+	return s
+}
+
+// drawioColor - Returns the colour for draw.io styles, which reserve ; and =.
+func drawioColor(color string) string {
+	if color == "" || strings.ContainsAny(color, ";=") {
+		return "none"
+	}
+	return color
+}
+
+// drawioStyle - Converts the svg fill and stroke styles used by the renderer.
+func drawioStyle(svgStyle string) string {
+	style := []string{}
+	for _, rule := range strings.Split(svgStyle, ";") {
+		kv := strings.SplitN(rule, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "fill":
+			style = append(style, "fillColor="+drawioColor(kv[1]))
+		case "stroke":
+			style = append(style, "strokeColor="+drawioColor(kv[1]))
+		}
+	}
+	return strings.Join(style, ";") + ";"
+}
+
+// drawioEdgeStyles - Line style for each connector type, like the svg connector styles.
+var drawioEdgeStyles = map[string]string{
+	"normal":         "endArrow=none;opacity=20;",
+	"bold":           "endArrow=none;opacity=80;",
+	"change":         "endArrow=block;endFill=1;opacity=60;dashed=1;dashPattern=6 6;",
+	"change-inertia": "endArrow=block;endFill=1;opacity=60;dashed=1;dashPattern=6 6;",
+}
+
+// drawioExport - Writes the rendered map in the draw.io format.
+// Grid lines, texts, nodes, labels and connectors are separate shapes at the same positions as in the svg drawing.
+// Node glyphs are approximated by circles, and the legend and breadcrumb are left out.
+func drawioExport(w io.Writer, m *hcl.Map, pg page) error {
+	// The layout is computed while drawing
+	drawing(ioutil.Discard, m, pg)
+
+	d := &drawioDiagramBuilder{
+		mapFrame: newMapFrame(m.Size),
+		cells:    []interface{}{drawioCell{ID: "0"}, drawioCell{ID: "1", Parent: "0"}},
+	}
+	drawioGrid(d, m)
+	drawioRegions(d, m)
+	for i, c := range m.Connectors {
+		if hasRoute(c) {
+			drawioConnector(d, m, c, i)
+		}
+	}
+	for _, n := range m.Nodes {
+		if n.Inertia != nil {
+			drawioInertia(d, m, n)
+		}
+	}
+	for _, n := range m.Nodes {
+		href := n.URL
+		if n.Submap != "" {
+			target := svgName(submapFile(pg.file, n))
+			href = strings.TrimSuffix(relativeLink(pg.output, target), ".svg") + ".drawio"
+		}
+		drawioNode(d, m, n, href)
+	}
+	drawioAccelerators(d, m)
+
+	f := drawioFile{
+		Host: "go-wardley",
+		Diagram: drawioDiagram{
+			ID:   "map",
+			Name: mapTitle(pg.file, m),
+			Model: drawioModel{
+				Page:       1,
+				PageWidth:  m.Size.Width,
+				PageHeight: m.Size.Height,
+				Background: drawioColor(m.Theme.Background),
+				Cells:      d.cells,
+			},
+		},
+	}
+	fmt.Fprint(w, xml.Header)
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	err := e.Encode(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode map: %w", err)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// drawioGrid - Adds the axes, stage separators, axis texts and the header, see grid and header.
+func drawioGrid(d *drawioDiagramBuilder, m *hcl.Map) {
+	margin, width, height := m.Size.Margin, m.Size.Width, m.Size.Height
+	xZero, xEnd := margin*2, width-margin*2
+	yZero, yEnd := height-margin*2, margin*2
+	yLength := height - margin*4
+	theme, fonts, axes := m.Theme, m.Font, m.Axes
+
+	axis := fmt.Sprintf("endArrow=block;endFill=1;endSize=6;strokeColor=%s;", drawioColor(theme.Axis))
+	d.line("axis-evolution", axis, xZero, yZero, xEnd, yZero)
+	d.line("axis-value-chain", axis, xZero, yZero, xZero, yEnd)
+	for i, x := range mapGrid.Stages[1:] {
+		d.line(fmt.Sprintf("axis-stage-%d", i+1), fmt.Sprintf("endArrow=none;strokeColor=%s;dashed=1;dashPattern=1 10;", drawioColor(theme.Grid)),
+			xZero+x, yZero, xZero+x, yEnd)
+	}
+	for i, x := range mapGrid.Stages {
+		d.text(fmt.Sprintf("axis-label-%d", i), xZero+x, height-margin, []string{axes.Stages[i]}, fonts.Axis, theme.Text, "start", 0)
+	}
+	d.text("axis-title-evolution", xEnd, height-2*margin-5, []string{axes.Evolution}, fonts.AxisTitle, theme.Text, "end", 0)
+
+	rotated := func(id, text string, font *hcl.Font, u, v int, anchor string) {
+		b := rotatedTextBox(xZero, yZero, u, v, []string{text}, font, anchor)
+		d.add(d.vertex(id, text, drawioTextStyle(font, theme.Text, "center", -90), b))
+	}
+	rotated("axis-label-invisible", axes.Invisible, fonts.Axis, 0, -5, "start")
+	rotated("axis-label-visible", axes.Visible, fonts.Axis, yLength, -5, "end")
+	rotated("axis-title-value-chain", axes.ValueChain, fonts.AxisTitle, yLength, fonts.AxisTitle.Size+5, "end")
+
+	if m.Meta == nil {
+		return
+	}
+	muted := &hcl.Font{Family: fonts.Family, Size: fonts.Size, Weight: fonts.Weight}
+	d.text("title", xZero, margin, []string{m.Meta.Title}, fonts.Title, theme.Text, "start", 0)
+	if m.Meta.Subtitle != "" {
+		d.text("subtitle", xZero, margin+fonts.Size+4, []string{m.Meta.Subtitle}, muted, theme.Muted, "start", 0)
+	}
+	if details := metaDetails(m.Meta); details != "" {
+		d.text("details", xEnd, margin, []string{details}, muted, theme.Muted, "end", 0)
+	}
+	if m.Meta.Source != "" {
+		b := textBox(xEnd, margin+fonts.Size+4, []string{m.Meta.Source}, muted, "end")
+		d.addObject(d.vertex("source", m.Meta.Source, drawioTextStyle(muted, theme.Muted, "right", 0), b), m.Meta.Source, "")
+	}
+}
+
+// drawioRegions - Adds the regions, node regions are polygons over the bounding box of their hull.
+func drawioRegions(d *drawioDiagramBuilder, m *hcl.Map) {
+	for _, a := range regionAreas(m) {
+		r := a.region
+		b := pointsBox(a.points)
+		style := fmt.Sprintf("fillColor=%s;strokeColor=none;opacity=%d;", drawioColor(r.Fill), int(math.Round(r.Opacity*100)))
+		if len(r.Nodes) > 0 {
+			coords := []string{}
+			for _, p := range a.points {
+				coords = append(coords, fmt.Sprintf("[%.3f,%.3f]", float64(p.X-b.x0)/float64(maxInt(b.x1-b.x0, 1)), float64(p.Y-b.y0)/float64(maxInt(b.y1-b.y0, 1))))
+			}
+			style = "shape=mxgraph.basic.polygon;polyCoords=[" + strings.Join(coords, ",") + "];polyline=0;" + style
+		}
+		d.add(d.vertex("region-"+r.ID, "", style, d.mapBox(b)))
+		if r.Label != "" {
+			d.text("region-"+r.ID+"-label", a.labelX+d.dx, a.labelY+d.dy, strings.Split(r.Label, "\n"), m.Font.Node, m.Theme.Text, "start", 0)
+		}
+	}
+}
+
+// drawioConnector - Adds the connector attached to its nodes, with the route points as waypoints.
+func drawioConnector(d *drawioDiagramBuilder, m *hcl.Map, c *hcl.Connector, i int) {
+	id := fmt.Sprintf("connector-%d", i)
+	style := fmt.Sprintf("html=0;strokeColor=%s;", drawioColor(c.Color)) + drawioEdgeStyles[c.Type]
+	if c.Shape == "curved" {
+		style += "curved=1;"
+	}
+	g := &drawioGeometry{Relative: "1", As: "geometry"}
+	if len(c.Route) > 2 {
+		g.Waypoint = &drawioWaypoint{As: "points"}
+		for _, p := range c.Route[1 : len(c.Route)-1] {
+			g.Waypoint.Points = append(g.Waypoint.Points, drawioPoint{X: p.X + d.dx, Y: p.Y + d.dy})
+		}
+	}
+	cell := drawioCell{ID: id, Style: style, Edge: "1", Source: "node-" + c.From, Target: "node-" + c.To, Geometry: g}
+	d.addObject(cell, c.URL, "")
+	if c.Type == "change-inertia" {
+		bar, angle := connectorInertiaBar(c)
+		d.add(d.vertex(id+"-inertia", "", fmt.Sprintf("fillColor=%s;strokeColor=none;opacity=60;rotation=%d;", drawioColor(m.Theme.ConnectorColor), int(math.Round(angle*180/math.Pi))),
+			d.mapBox(bar)))
+	}
+	if c.Label != "" {
+		d.text(id+"-label", c.LabelX+d.dx, c.LabelY+d.dy, strings.Split(c.Label, "\n"), m.Font.Connector, m.Theme.Text, c.LabelAnchor, 0)
+	}
+}
+
+// drawioNode - Adds the node glyph and its label, anchors are only text.
+func drawioNode(d *drawioDiagramBuilder, m *hcl.Map, n *hcl.Node, href string) {
+	id := "node-" + n.ID
+	lines := strings.Split(n.Label, "\n")
+	if n.IsAnchor() {
+		bold := *m.Font.Node
+		bold.Weight = "bold"
+		b := textBox(n.LabelX+d.dx, n.LabelY+d.dy, lines, &bold, n.LabelAnchor)
+		d.addObject(d.vertex(id, n.Label, drawioTextStyle(&bold, m.Theme.Text, drawioAlign(n.LabelAnchor), 0), b), href, n.Description)
+		return
+	}
+	if n.Method != "" {
+		d.add(d.vertex(id+"-method", "", "ellipse;"+drawioStyle(methodStyles[n.Method]), d.mapBox(circleBox(n.X, n.Y, methodRadius))))
+	}
+	r := glyphRadius(n.Type)
+	shape := "ellipse;"
+	if n.Type == "ecosystem" {
+		shape = "ellipse;shape=doubleEllipse;"
+	}
+	style := shape + fmt.Sprintf("fillColor=%s;strokeColor=%s;", drawioColor(n.Fill), drawioColor(n.Color))
+	d.addObject(d.vertex(id, "", style, d.mapBox(circleBox(n.X, n.Y, r))), href, n.Description)
+	if n.Submap != "" {
+		d.add(d.vertex(id+"-submap", "", fmt.Sprintf("fillColor=%s;strokeColor=none;", drawioColor(n.Color)), d.mapBox(circleBox(n.X, n.Y, 2))))
+	}
+	d.text(id+"-label", n.LabelX+d.dx, n.LabelY+d.dy, lines, m.Font.Node, m.Theme.Text, n.LabelAnchor, 0)
+}
+
+// drawioInertia - Adds the node inertia bar and its label.
+func drawioInertia(d *drawioDiagramBuilder, m *hcl.Map, n *hcl.Node) {
+	id := "node-" + n.ID + "-inertia"
+	d.add(d.vertex(id, "", fmt.Sprintf("fillColor=%s;strokeColor=none;opacity=60;", drawioColor(m.Theme.ConnectorColor)), d.mapBox(inertiaBar(n))))
+	if n.Inertia.Label != "" {
+		x, y, lines := inertiaLabel(n, m.Font.Node)
+		d.text(id+"-label", x+d.dx, y+d.dy, lines, m.Font.Node, m.Theme.Text, "middle", 0)
+	}
+}
+
+// drawioAccelerators - Adds the accelerator arrows and their labels.
+// The line ends at the tip of the draw.io arrow head.
+func drawioAccelerators(d *drawioDiagramBuilder, m *hcl.Map) {
+	fill := fmt.Sprintf("fillColor=%s;strokeColor=none;opacity=60;", drawioColor(m.Theme.ConnectorColor))
+	for i, a := range m.Accelerators {
+		id := fmt.Sprintf("accelerator-%d", i)
+		arrow := acceleratorShape(a)
+		d.line(id, fmt.Sprintf("endArrow=block;endFill=1;endSize=3;strokeWidth=10;opacity=60;strokeColor=%s;", drawioColor(m.Theme.ConnectorColor)),
+			arrow.x0+d.dx, a.Y+d.dy, arrow.tip+d.dx, a.Y+d.dy)
+		if arrow.bar != nil {
+			d.add(d.vertex(id+"-bar", "", fill, d.mapBox(*arrow.bar)))
+		}
+		if a.Label != "" {
+			x, y, lines := acceleratorLabel(a, m.Font.Node)
+			d.text(id+"-label", x+d.dx, y+d.dy, lines, m.Font.Node, m.Theme.Text, "middle", 0)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// drawioCells - Returns the attributes of the cells and objects in the diagram by id, in document order.
+func drawioCells(t *testing.T, b []byte) ([]string, map[string]map[string]string) {
+	t.Helper()
+	ids := []string{}
+	cells := map[string]map[string]string{}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid xml: %s", err)
+		}
+		e, ok := tok.(xml.StartElement)
+		if !ok || (e.Name.Local != "mxCell" && e.Name.Local != "UserObject") {
+			continue
+		}
+		attrs := map[string]string{"element": e.Name.Local}
+		for _, a := range e.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+		// Cells wrapped in objects take the object id
+		if attrs["id"] == "" {
+			continue
+		}
+		if _, ok := cells[attrs["id"]]; ok {
+			t.Errorf("duplicate cell id %s", attrs["id"])
+		}
+		ids = append(ids, attrs["id"])
+		cells[attrs["id"]] = attrs
+	}
+	return ids, cells
+}
+
+func TestDrawioExport(t *testing.T) {
+	m, pg := testMap(t, "features", featureMap)
+	buf := new(bytes.Buffer)
+	err := drawioExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, cells := drawioCells(t, buf.Bytes())
+	if len(ids) < 2 || ids[0] != "0" || ids[1] != "1" || cells["1"]["parent"] != "0" {
+		t.Fatalf("expected the root cells first, got %v", ids)
+	}
+	for _, id := range []string{
+		"axis-evolution", "title", "subtitle", "details", "source",
+		"region-pioneers", "region-pioneers-label", "region-team",
+		"node-user", "node-web", "node-web-method", "node-web-label", "node-web-inertia", "node-web-inertia-label",
+		"node-market", "node-eco",
+		"connector-0", "connector-0-label", "connector-2-inertia",
+		"accelerator-0", "accelerator-0-label", "accelerator-1", "accelerator-1-bar",
+	} {
+		if _, ok := cells[id]; !ok {
+			t.Errorf("missing cell %s", id)
+		}
+	}
+	if _, ok := cells["accelerator-0-bar"]; ok {
+		t.Errorf("expected no bar on the accelerator")
+	}
+	if cells["details"]["value"] != "Author · 2020-03-01 · 1.2" {
+		t.Errorf("unexpected details %q", cells["details"]["value"])
+	}
+	for _, id := range []string{"node-web", "source"} {
+		if cells[id]["element"] != "UserObject" || cells[id]["link"] == "" {
+			t.Errorf("expected %s to be an object with a link, got %v", id, cells[id])
+		}
+	}
+	if cells["node-web"]["tooltip"] != "Web site" {
+		t.Errorf("expected the node description as tooltip, got %v", cells["node-web"])
+	}
+	// Connectors are attached to the node cells
+	for i, c := range m.Connectors {
+		id := fmt.Sprintf("connector-%d", i)
+		cell := cells[id]
+		if cell["edge"] != "1" || cell["source"] != "node-"+c.From || cell["target"] != "node-"+c.To {
+			t.Errorf("expected %s from node-%s to node-%s, got %v", id, c.From, c.To, cell)
+		}
+		if _, ok := cells[cell["source"]]; !ok {
+			t.Errorf("%s source %s is not a cell", id, cell["source"])
+		}
+		if _, ok := cells[cell["target"]]; !ok {
+			t.Errorf("%s target %s is not a cell", id, cell["target"])
+		}
+	}
+}
+
+func TestDrawioExportUnknownNode(t *testing.T) {
+	m, pg := testMap(t, "unknown", unknownNodeMap)
+	buf := new(bytes.Buffer)
+	err := drawioExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cells := drawioCells(t, buf.Bytes())
+	if _, ok := cells["connector-0"]; ok {
+		t.Errorf("expected the connector to an unknown node to be skipped")
+	}
+	if _, ok := cells["connector-0-inertia"]; ok {
+		t.Errorf("expected the inertia of the connector to an unknown node to be skipped")
+	}
+	if _, ok := cells["connector-1"]; !ok {
+		t.Errorf("expected the a to b connector")
+	}
+}
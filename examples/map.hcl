@@ -23,11 +23,9 @@ size {
 #   }
 # }
 
-# Anchor
 node user {
   label       = "User"
-  fill        = "black"
-  color       = "black"
+  type        = "anchor"
   visibility  = 1
   evolution   = "custom"
   x           = 1
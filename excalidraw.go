@@ -0,0 +1,385 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// excalidrawFont - Normal font family, its glyph widths are closer to the label placement estimates than the hand drawn one.
+const excalidrawFont = 2
+
+type excalidrawScene struct {
+	Type     string                 `json:"type"`
+	Version  int                    `json:"version"`
+	Source   string                 `json:"source"`
+	Elements []*excalidrawElement   `json:"elements"`
+	AppState map[string]interface{} `json:"appState"`
+	Files    map[string]interface{} `json:"files"`
+}
+
+// excalidrawElement - Scene element, linear and text fields are only set for lines, arrows and texts.
+type excalidrawElement struct {
+	ID              string              `json:"id"`
+	Type            string              `json:"type"`
+	X               float64             `json:"x"`
+	Y               float64             `json:"y"`
+	Width           float64             `json:"width"`
+	Height          float64             `json:"height"`
+	Angle           float64             `json:"angle"`
+	StrokeColor     string              `json:"strokeColor"`
+	BackgroundColor string              `json:"backgroundColor"`
+	FillStyle       string              `json:"fillStyle"`
+	StrokeWidth     int                 `json:"strokeWidth"`
+	StrokeStyle     string              `json:"strokeStyle"`
+	Roughness       int                 `json:"roughness"`
+	Opacity         int                 `json:"opacity"`
+	GroupIDs        []string            `json:"groupIds"`
+	FrameID         *string             `json:"frameId"`
+	Roundness       *string             `json:"roundness"`
+	Seed            int                 `json:"seed"`
+	Version         int                 `json:"version"`
+	VersionNonce    int                 `json:"versionNonce"`
+	IsDeleted       bool                `json:"isDeleted"`
+	BoundElements   []excalidrawBinding `json:"boundElements"`
+	Updated         int                 `json:"updated"`
+	Link            *string             `json:"link"`
+	Locked          bool                `json:"locked"`
+
+	Text          string  `json:"text,omitempty"`
+	OriginalText  string  `json:"originalText,omitempty"`
+	FontSize      int     `json:"fontSize,omitempty"`
+	FontFamily    int     `json:"fontFamily,omitempty"`
+	TextAlign     string  `json:"textAlign,omitempty"`
+	VerticalAlign string  `json:"verticalAlign,omitempty"`
+	LineHeight    float64 `json:"lineHeight,omitempty"`
+
+	Points         [][2]float64        `json:"points,omitempty"`
+	StartBinding   *excalidrawEndpoint `json:"startBinding,omitempty"`
+	EndBinding     *excalidrawEndpoint `json:"endBinding,omitempty"`
+	StartArrowhead *string             `json:"startArrowhead"`
+	EndArrowhead   *string             `json:"endArrowhead"`
+}
+
+type excalidrawBinding struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type excalidrawEndpoint struct {
+	ElementID string  `json:"elementId"`
+	Focus     float64 `json:"focus"`
+	Gap       float64 `json:"gap"`
+}
+
+// excalidrawBuilder - Collects the elements in drawing order, in absolute drawing coordinates.
+type excalidrawBuilder struct {
+	mapFrame
+	elements []*excalidrawElement
+	byID     map[string]*excalidrawElement
+}
+
+// add - Adds the element with the shared defaults, seeds are deterministic so exports are reproducible.
+func (e *excalidrawBuilder) add(el *excalidrawElement) *excalidrawElement {
+	el.Seed = len(e.elements) + 1
+	el.VersionNonce = len(e.elements) + 1
+	el.Version = 1
+	el.Updated = 1
+	el.GroupIDs = []string{}
+	el.Roughness = 1
+	if el.Opacity == 0 {
+		el.Opacity = 100
+	}
+	if el.StrokeWidth == 0 {
+		el.StrokeWidth = 1
+	}
+	if el.StrokeStyle == "" {
+		el.StrokeStyle = "solid"
+	}
+	if el.FillStyle == "" {
+		el.FillStyle = "solid"
+	}
+	if el.BackgroundColor == "" {
+		el.BackgroundColor = "transparent"
+	}
+	e.elements = append(e.elements, el)
+	e.byID[el.ID] = el
+	return el
+}
+
+// shape - Adds a rectangle or ellipse covering the box, given in absolute coordinates.
+func (e *excalidrawBuilder) shape(id, kind string, b box, stroke, fill string) *excalidrawElement {
+	return e.add(&excalidrawElement{ID: id, Type: kind, X: float64(b.x0), Y: float64(b.y0),
+		Width: float64(b.x1 - b.x0), Height: float64(b.y1 - b.y0), StrokeColor: stroke, BackgroundColor: fill})
+}
+
+// line - Adds a line or an arrow through the points, given in absolute coordinates.
+func (e *excalidrawBuilder) line(id, kind string, points []hcl.Point, stroke string, arrow bool) *excalidrawElement {
+	el := &excalidrawElement{ID: id, Type: kind, X: float64(points[0].X), Y: float64(points[0].Y), StrokeColor: stroke}
+	x0, y0, x1, y1 := points[0].X, points[0].Y, points[0].X, points[0].Y
+	for _, p := range points {
+		el.Points = append(el.Points, [2]float64{float64(p.X - points[0].X), float64(p.Y - points[0].Y)})
+		x0, y0, x1, y1 = minInt(x0, p.X), minInt(y0, p.Y), maxInt(x1, p.X), maxInt(y1, p.Y)
+	}
+	el.Width, el.Height = float64(x1-x0), float64(y1-y0)
+	if arrow {
+		head := "arrow"
+		el.EndArrowhead = &head
+	}
+	return e.add(el)
+}
+
+// text - Adds multi line text with its first baseline at x, y in absolute coordinates.
+func (e *excalidrawBuilder) text(id string, x, y int, lines []string, font *hcl.Font, color, anchor string) *excalidrawElement {
+	return e.textBox(id, textBox(x, y, lines, font, anchor), lines, font, color, anchor)
+}
+
+// textBox - Adds multi line text covering the box.
+func (e *excalidrawBuilder) textBox(id string, b box, lines []string, font *hcl.Font, color, anchor string) *excalidrawElement {
+	text := strings.Join(lines, "\n")
+	align := map[string]string{"middle": "center", "end": "right"}[anchor]
+	if align == "" {
+		align = "left"
+	}
+	return e.add(&excalidrawElement{ID: id, Type: "text", X: float64(b.x0), Y: float64(b.y0),
+		Width: float64(b.x1 - b.x0), Height: float64(b.y1 - b.y0), StrokeColor: color,
+		Text: text, OriginalText: text, FontSize: font.Size, FontFamily: excalidrawFont,
+		TextAlign: align, VerticalAlign: "top", LineHeight: float64(font.Size+3) / float64(font.Size)})
+}
+
+// bind - Attaches the end of the arrow to the element.
+func (e *excalidrawBuilder) bind(arrow *excalidrawElement, id string, end bool) {
+	target, ok := e.byID[id]
+	if !ok {
+		return
+	}
+	binding := &excalidrawEndpoint{ElementID: id, Gap: 1}
+	if end {
+		arrow.EndBinding = binding
+	} else {
+		arrow.StartBinding = binding
+	}
+	target.BoundElements = append(target.BoundElements, excalidrawBinding{ID: arrow.ID, Type: "arrow"})
+}
+
+// excalidrawExport - Writes the rendered map as an Excalidraw scene.
+// Axes, stage separators, regions, nodes, labels and connectors are positioned as in the svg drawing.
+func excalidrawExport(w io.Writer, m *hcl.Map, pg page) error {
+	// The layout is computed while drawing
+	drawing(ioutil.Discard, m, pg)
+
+	e := &excalidrawBuilder{
+		mapFrame: newMapFrame(m.Size),
+		elements: []*excalidrawElement{},
+		byID:     map[string]*excalidrawElement{},
+	}
+	excalidrawGrid(e, m)
+	excalidrawRegions(e, m)
+	for i, c := range m.Connectors {
+		if hasRoute(c) {
+			excalidrawConnector(e, m, c, i)
+		}
+	}
+	for _, n := range m.Nodes {
+		if n.Inertia != nil {
+			excalidrawInertia(e, m, n)
+		}
+	}
+	for _, n := range m.Nodes {
+		href := n.URL
+		if n.Submap != "" {
+			target := svgName(submapFile(pg.file, n))
+			href = strings.TrimSuffix(relativeLink(pg.output, target), ".svg") + ".excalidraw"
+		}
+		excalidrawNode(e, m, n, href)
+	}
+	// Connectors are drawn below the nodes, so they are bound once the nodes exist
+	for i, c := range m.Connectors {
+		arrow := e.byID[fmt.Sprintf("connector-%d", i)]
+		if arrow != nil {
+			e.bind(arrow, "node-"+c.From, false)
+			e.bind(arrow, "node-"+c.To, true)
+		}
+	}
+	excalidrawAccelerators(e, m)
+
+	scene := excalidrawScene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "https://github.com/DavidGamba/go-wardley",
+		Elements: e.elements,
+		AppState: map[string]interface{}{"viewBackgroundColor": m.Theme.Background, "gridSize": nil},
+		Files:    map[string]interface{}{},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(scene)
+	if err != nil {
+		return fmt.Errorf("failed to encode map: %w", err)
+	}
+	return nil
+}
+
+// excalidrawGrid - Adds the axes, stage separators, axis texts and the map header, see grid and header.
+func excalidrawGrid(e *excalidrawBuilder, m *hcl.Map) {
+	margin, width, height := m.Size.Margin, m.Size.Width, m.Size.Height
+	xZero, xEnd := margin*2, width-margin*2
+	yZero, yEnd := height-margin*2, margin*2
+	yLength := height - margin*4
+	theme, fonts, axes := m.Theme, m.Font, m.Axes
+
+	e.line("axis-evolution", "arrow", []hcl.Point{{X: xZero, Y: yZero}, {X: xEnd, Y: yZero}}, theme.Axis, true)
+	e.line("axis-value-chain", "arrow", []hcl.Point{{X: xZero, Y: yZero}, {X: xZero, Y: yEnd}}, theme.Axis, true)
+	for i, x := range mapGrid.Stages[1:] {
+		stage := e.line(fmt.Sprintf("axis-stage-%d", i+1), "line", []hcl.Point{{X: xZero + x, Y: yZero}, {X: xZero + x, Y: yEnd}}, theme.Grid, false)
+		stage.StrokeStyle = "dotted"
+	}
+	for i, x := range mapGrid.Stages {
+		e.text(fmt.Sprintf("axis-label-%d", i), xZero+x, height-margin, []string{axes.Stages[i]}, fonts.Axis, theme.Text, "start")
+	}
+	e.text("axis-title-evolution", xEnd, height-2*margin-5, []string{axes.Evolution}, fonts.AxisTitle, theme.Text, "end")
+	rotated := func(id, text string, font *hcl.Font, u, v int, anchor string) {
+		b := rotatedTextBox(xZero, yZero, u, v, []string{text}, font, anchor)
+		e.textBox(id, b, []string{text}, font, theme.Text, "middle").Angle = 3 * math.Pi / 2
+	}
+	rotated("axis-label-invisible", axes.Invisible, fonts.Axis, 0, -5, "start")
+	rotated("axis-label-visible", axes.Visible, fonts.Axis, yLength, -5, "end")
+	rotated("axis-title-value-chain", axes.ValueChain, fonts.AxisTitle, yLength, fonts.AxisTitle.Size+5, "end")
+
+	if m.Meta == nil {
+		return
+	}
+	muted := &hcl.Font{Family: fonts.Family, Size: fonts.Size, Weight: fonts.Weight}
+	e.text("title", xZero, margin, []string{m.Meta.Title}, fonts.Title, theme.Text, "start")
+	if m.Meta.Subtitle != "" {
+		e.text("subtitle", xZero, margin+fonts.Size+4, []string{m.Meta.Subtitle}, muted, theme.Muted, "start")
+	}
+	if details := metaDetails(m.Meta); details != "" {
+		e.text("details", xEnd, margin, []string{details}, muted, theme.Muted, "end")
+	}
+	if m.Meta.Source != "" {
+		source := m.Meta.Source
+		e.text("source", xEnd, margin+fonts.Size+4, []string{source}, muted, theme.Muted, "end").Link = &source
+	}
+}
+
+// excalidrawRegions - Adds the regions as filled closed lines.
+func excalidrawRegions(e *excalidrawBuilder, m *hcl.Map) {
+	for _, a := range regionAreas(m) {
+		r := a.region
+		closed := []hcl.Point{}
+		for _, p := range append(a.points, a.points[0]) {
+			closed = append(closed, e.mapPoint(p.X, p.Y))
+		}
+		region := e.line("region-"+r.ID, "line", closed, "transparent", false)
+		region.BackgroundColor = r.Fill
+		region.Opacity = int(math.Round(r.Opacity * 100))
+		if r.Label != "" {
+			e.text("region-"+r.ID+"-label", a.labelX+e.dx, a.labelY+e.dy, strings.Split(r.Label, "\n"), m.Font.Node, m.Theme.Text, "start")
+		}
+	}
+}
+
+// excalidrawNode - Adds the node glyph and its label, anchors are only text.
+func excalidrawNode(e *excalidrawBuilder, m *hcl.Map, n *hcl.Node, href string) {
+	id := "node-" + n.ID
+	lines := strings.Split(n.Label, "\n")
+	link := func(el *excalidrawElement) {
+		if href != "" {
+			el.Link = &href
+		}
+	}
+	if n.IsAnchor() {
+		bold := *m.Font.Node
+		bold.Weight = "bold"
+		link(e.text(id, n.LabelX+e.dx, n.LabelY+e.dy, lines, &bold, m.Theme.Text, n.LabelAnchor))
+		return
+	}
+	if n.Method != "" {
+		stroke, fill := styleColors(methodStyles[n.Method])
+		e.shape(id+"-method", "ellipse", e.mapBox(circleBox(n.X, n.Y, methodRadius)), stroke, fill)
+	}
+	r := glyphRadius(n.Type)
+	link(e.shape(id, "ellipse", e.mapBox(circleBox(n.X, n.Y, r)), n.Color, n.Fill))
+	if n.Type == "ecosystem" {
+		e.shape(id+"-ring", "ellipse", e.mapBox(circleBox(n.X, n.Y, r*6/10)), n.Color, "transparent")
+	}
+	if n.Submap != "" {
+		e.shape(id+"-submap", "ellipse", e.mapBox(circleBox(n.X, n.Y, 2)), "transparent", n.Color)
+	}
+	e.text(id+"-label", n.LabelX+e.dx, n.LabelY+e.dy, lines, m.Font.Node, m.Theme.Text, n.LabelAnchor)
+}
+
+// excalidrawConnector - Adds the connector arrow bound to its nodes, change connectors are dashed.
+func excalidrawConnector(e *excalidrawBuilder, m *hcl.Map, c *hcl.Connector, i int) {
+	id := fmt.Sprintf("connector-%d", i)
+	points := []hcl.Point{}
+	for _, p := range polyline(c) {
+		points = append(points, e.mapPoint(p.X, p.Y))
+	}
+	change := c.Type == "change" || c.Type == "change-inertia"
+	arrow := e.line(id, "arrow", points, c.Color, change)
+	switch c.Type {
+	case "normal":
+		arrow.Opacity = 20
+	case "bold":
+		arrow.Opacity = 80
+		arrow.StrokeWidth = 2
+	default:
+		arrow.Opacity = 60
+		arrow.StrokeStyle = "dashed"
+	}
+	if c.URL != "" {
+		arrow.Link = &c.URL
+	}
+	if c.Type == "change-inertia" {
+		b, angle := connectorInertiaBar(c)
+		bar := e.shape(id+"-inertia", "rectangle", e.mapBox(b), "transparent", m.Theme.ConnectorColor)
+		bar.Angle = angle
+		bar.Opacity = 60
+	}
+	if c.Label != "" {
+		e.text(id+"-label", c.LabelX+e.dx, c.LabelY+e.dy, strings.Split(c.Label, "\n"), m.Font.Connector, m.Theme.Text, c.LabelAnchor)
+	}
+}
+
+// excalidrawInertia - Adds the node inertia bar and its label.
+func excalidrawInertia(e *excalidrawBuilder, m *hcl.Map, n *hcl.Node) {
+	id := "node-" + n.ID + "-inertia"
+	e.shape(id, "rectangle", e.mapBox(inertiaBar(n)), "transparent", m.Theme.ConnectorColor).Opacity = 60
+	if n.Inertia.Label != "" {
+		x, y, lines := inertiaLabel(n, m.Font.Node)
+		e.text(id+"-label", x+e.dx, y+e.dy, lines, m.Font.Node, m.Theme.Text, "middle")
+	}
+}
+
+// excalidrawAccelerators - Adds the accelerator arrows and their labels.
+func excalidrawAccelerators(e *excalidrawBuilder, m *hcl.Map) {
+	for i, a := range m.Accelerators {
+		id := fmt.Sprintf("accelerator-%d", i)
+		shape := acceleratorShape(a)
+		arrow := e.line(id, "arrow", []hcl.Point{e.mapPoint(shape.x0, a.Y), e.mapPoint(shape.tip, a.Y)}, m.Theme.ConnectorColor, true)
+		arrow.StrokeWidth = 10
+		arrow.Opacity = 60
+		if shape.bar != nil {
+			e.shape(id+"-bar", "rectangle", e.mapBox(*shape.bar), "transparent", m.Theme.ConnectorColor).Opacity = 60
+		}
+		if a.Label != "" {
+			x, y, lines := acceleratorLabel(a, m.Font.Node)
+			e.text(id+"-label", x+e.dx, y+e.dy, lines, m.Font.Node, m.Theme.Text, "middle")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// excalidrawElements - Returns the scene elements by id.
+func excalidrawElements(t *testing.T, m *hcl.Map, pg page) map[string]*excalidrawElement {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	err := excalidrawExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scene excalidrawScene
+	err = json.Unmarshal(buf.Bytes(), &scene)
+	if err != nil {
+		t.Fatalf("invalid json: %s", err)
+	}
+	if scene.Type != "excalidraw" || scene.Version != 2 {
+		t.Errorf("unexpected scene type %s version %d", scene.Type, scene.Version)
+	}
+	elements := map[string]*excalidrawElement{}
+	for _, el := range scene.Elements {
+		if _, ok := elements[el.ID]; ok {
+			t.Errorf("duplicate element id %s", el.ID)
+		}
+		elements[el.ID] = el
+	}
+	return elements
+}
+
+func TestExcalidrawExport(t *testing.T) {
+	m, pg := testMap(t, "features", featureMap)
+	elements := excalidrawElements(t, m, pg)
+	for id, kind := range map[string]string{
+		"axis-evolution":         "arrow",
+		"axis-stage-1":           "line",
+		"title":                  "text",
+		"subtitle":               "text",
+		"region-pioneers":        "line",
+		"region-team":            "line",
+		"region-team-label":      "text",
+		"node-user":              "text",
+		"node-web":               "ellipse",
+		"node-web-method":        "ellipse",
+		"node-web-inertia":       "rectangle",
+		"node-web-inertia-label": "text",
+		"node-eco-ring":          "ellipse",
+		"connector-0":            "arrow",
+		"connector-0-label":      "text",
+		"connector-2-inertia":    "rectangle",
+		"accelerator-0":          "arrow",
+		"accelerator-1":          "arrow",
+		"accelerator-1-bar":      "rectangle",
+	} {
+		el, ok := elements[id]
+		if !ok {
+			t.Errorf("missing element %s", id)
+			continue
+		}
+		if el.Type != kind {
+			t.Errorf("expected %s to be a %s, got %s", id, kind, el.Type)
+		}
+	}
+	if _, ok := elements["accelerator-0-bar"]; ok {
+		t.Errorf("expected no bar on the accelerator")
+	}
+	if el := elements["details"]; el == nil || el.Text != "Author · 2020-03-01 · 1.2" || el.TextAlign != "right" {
+		t.Errorf("expected the author, date and version on the top right, got %v", el)
+	}
+	if el := elements["source"]; el == nil || el.Link == nil || *el.Link != "https://example.com/?a=1&b=2" {
+		t.Errorf("expected the linked source, got %v", el)
+	}
+	if el := elements["node-web"]; el.Link == nil || *el.Link != "https://example.com/web" {
+		t.Errorf("expected the node link, got %v", el.Link)
+	}
+	// Arrows are bound to their nodes both ways
+	for i, c := range m.Connectors {
+		id := fmt.Sprintf("connector-%d", i)
+		arrow := elements[id]
+		if arrow.StartBinding == nil || arrow.StartBinding.ElementID != "node-"+c.From {
+			t.Errorf("expected %s to start at node-%s, got %v", id, c.From, arrow.StartBinding)
+		}
+		if arrow.EndBinding == nil || arrow.EndBinding.ElementID != "node-"+c.To {
+			t.Errorf("expected %s to end at node-%s, got %v", id, c.To, arrow.EndBinding)
+		}
+		for _, node := range []string{c.From, c.To} {
+			found := false
+			for _, b := range elements["node-"+node].BoundElements {
+				found = found || b.ID == id
+			}
+			if !found {
+				t.Errorf("expected node-%s to list %s as bound", node, id)
+			}
+		}
+	}
+}
+
+func TestExcalidrawExportUnknownNode(t *testing.T) {
+	m, pg := testMap(t, "unknown", unknownNodeMap)
+	elements := excalidrawElements(t, m, pg)
+	if _, ok := elements["connector-0"]; ok {
+		t.Errorf("expected the connector to an unknown node to be skipped")
+	}
+	if _, ok := elements["connector-1"]; !ok {
+		t.Errorf("expected the a to b connector")
+	}
+}
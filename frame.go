@@ -0,0 +1,62 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// mapFrame - Converts map coordinates to drawing coordinates.
+// Map coordinates have their origin where the axes meet and y grows upwards into negative values.
+type mapFrame struct {
+	// dx, dy - Drawing position of the map origin.
+	dx, dy int
+}
+
+func newMapFrame(size *hcl.Size) mapFrame {
+	return mapFrame{dx: size.Margin * 2, dy: size.Height - size.Margin*2}
+}
+
+// mapPoint - Returns the map point in drawing coordinates.
+func (f mapFrame) mapPoint(x, y int) hcl.Point {
+	return hcl.Point{X: x + f.dx, Y: y + f.dy}
+}
+
+// mapBox - Returns the map box in drawing coordinates.
+func (f mapFrame) mapBox(b box) box {
+	return box{x0: b.x0 + f.dx, y0: b.y0 + f.dy, x1: b.x1 + f.dx, y1: b.y1 + f.dy}
+}
+
+// mapScale - Returns the max evolution x per stage and the max visibility of the nodes and the accelerators not attached to a node.
+func mapScale(m *hcl.Map) ([]int, int) {
+	maxX := make([]int, len(m.Axes.Stages))
+	maxY := 0
+	for _, n := range m.Nodes {
+		maxX[n.Stage] = maxInt(maxX[n.Stage], n.EvolutionX)
+		maxY = maxInt(maxY, n.Visibility)
+	}
+	for _, a := range m.Accelerators {
+		if a.Node != "" {
+			continue
+		}
+		maxX[a.Stage] = maxInt(maxX[a.Stage], a.EvolutionX)
+		maxY = maxInt(maxY, a.Visibility)
+	}
+	return maxX, maxY
+}
+
+// circleBox - Returns the box around the circle.
+func circleBox(x, y, r int) box {
+	return box{x0: x - r, y0: y - r, x1: x + r, y1: y + r}
+}
+
+// hasRoute - Reports whether the connector was routed, connectors to unknown nodes have no route.
+func hasRoute(c *hcl.Connector) bool {
+	return len(c.Route) >= 2
+}
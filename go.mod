@@ -9,6 +9,7 @@ require (
 	github.com/fsnotify/fsnotify v1.6.0
 	github.com/hashicorp/hcl/v2 v2.16.2
 	github.com/zclconf/go-cty v1.13.1
+	gopkg.in/yaml.v3 v3.0.1
 
 	// workaround for error: //go:linkname must refer to declared function or variable
 	golang.org/x/sys v0.6.0 // indirect
@@ -0,0 +1,79 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Accelerator - Strategic play that speeds up or slows down evolution, like open sourcing or patents.
+// Placed next to a node or at its own evolution and visibility.
+type Accelerator struct {
+	Label string `hcl:"label,optional" json:"label"`
+	// Node - Node the accelerator is attached to.
+	Node       string `hcl:"node,optional" json:"node"`
+	Evolution  string `hcl:"evolution,optional" json:"evolution"`
+	EvolutionX int    `hcl:"x,optional" json:"x"`
+	Visibility int    `hcl:"visibility,optional" json:"visibility"`
+	// Direction - forward accelerates evolution, backward is a de-accelerator.
+	Direction string `hcl:"direction,optional" json:"direction"`
+	Stage     int    `json:"stage"`
+	X         int    `json:"pixel_x"`
+	Y         int    `json:"pixel_y"`
+}
+
+func (a *Accelerator) String() string {
+	return fmt.Sprintf("Label='%s', Node=%s, Evolution=%s, X=%d, Visibility=%d, Direction=%s", a.Label, a.Node, a.Evolution, a.EvolutionX, a.Visibility, a.Direction)
+}
+
+// AcceleratorDirections - Valid accelerator direction values.
+var AcceleratorDirections = []string{"forward", "backward"}
+
+var acceleratorDefaults = Accelerator{
+	EvolutionX: 1,
+	Direction:  "forward",
+}
+
+// validateAccelerator - Checks the accelerator position and resolves its evolution stage.
+func validateAccelerator(m *Map, a *Accelerator, nodes map[string]bool, r hcl.Range) hcl.Diagnostics {
+	diags := hcl.Diagnostics{}
+	invalid := func(detail string) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid accelerator",
+			Detail:   detail,
+			Subject:  &r,
+		})
+	}
+	if !contains(AcceleratorDirections, a.Direction) {
+		invalid(fmt.Sprintf("Accelerator direction '%s' must be one of %q.", a.Direction, AcceleratorDirections))
+	}
+	if a.Node != "" {
+		if a.Evolution != "" || a.Visibility != 0 {
+			invalid(fmt.Sprintf("Accelerator attached to node '%s' can't set evolution or visibility.", a.Node))
+		}
+		if !nodes[a.Node] {
+			invalid(fmt.Sprintf("Accelerator node '%s' doesn't exist.", a.Node))
+		}
+		return diags
+	}
+	if a.Evolution == "" || a.Visibility < 1 {
+		invalid("Accelerator requires a node, or an evolution and a visibility of 1 or greater.")
+		return diags
+	}
+	stage, ok := m.Axes.Stage(a.Evolution)
+	if !ok {
+		invalid(fmt.Sprintf("Accelerator evolution '%s' doesn't match any of the axes stages: %q.", a.Evolution, m.Axes.Stages))
+		return diags
+	}
+	a.Stage = stage
+	return diags
+}
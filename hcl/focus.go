@@ -0,0 +1,103 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"fmt"
+)
+
+// FocusDirections - Valid focus direction values.
+// down follows the dependencies of the node, up follows its dependents.
+var FocusDirections = []string{"down", "up", "both"}
+
+// Focus - Reduces the map to the given node and its transitive dependency closure.
+// Depth limits the number of dependency steps followed, 0 follows the whole closure.
+// Change connectors from a kept node keep the node they point to.
+// Connectors, regions and accelerators that reference dropped nodes are dropped.
+func (m *Map) Focus(id string, depth int, direction string) error {
+	if !contains(FocusDirections, direction) {
+		return fmt.Errorf("focus direction '%s' must be one of %q", direction, FocusDirections)
+	}
+	if depth < 0 {
+		return fmt.Errorf("focus depth must be 0 or greater, got %d", depth)
+	}
+	g := newDependencyGraph(m)
+	if _, ok := g.nodes[id]; !ok {
+		return fmt.Errorf("focus node '%s' doesn't exist", id)
+	}
+
+	keep := map[string]bool{id: true}
+	follow := func(edges map[string][]string) {
+		level := []string{id}
+		for step := 0; len(level) > 0 && (depth == 0 || step < depth); step++ {
+			next := []string{}
+			for _, n := range level {
+				for _, e := range edges[n] {
+					if !keep[e] {
+						keep[e] = true
+						next = append(next, e)
+					}
+				}
+			}
+			level = next
+		}
+	}
+	if direction == "down" || direction == "both" {
+		follow(g.out)
+	}
+	if direction == "up" || direction == "both" {
+		follow(g.in)
+	}
+	for _, c := range m.Connectors {
+		if !c.IsDependency() && keep[c.From] {
+			keep[c.To] = true
+		}
+	}
+
+	nodes := []*Node{}
+	for _, n := range m.Nodes {
+		if keep[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	m.Nodes = nodes
+	connectors := []*Connector{}
+	for _, c := range m.Connectors {
+		if keep[c.From] && keep[c.To] {
+			connectors = append(connectors, c)
+		}
+	}
+	m.Connectors = connectors
+	regions := []*Region{}
+	for _, r := range m.Regions {
+		if len(r.Nodes) == 0 {
+			regions = append(regions, r)
+			continue
+		}
+		members := []string{}
+		for _, n := range r.Nodes {
+			if keep[n] {
+				members = append(members, n)
+			}
+		}
+		if len(members) > 0 {
+			r.Nodes = members
+			regions = append(regions, r)
+		}
+	}
+	m.Regions = regions
+	accelerators := []*Accelerator{}
+	for _, a := range m.Accelerators {
+		if a.Node == "" || keep[a.Node] {
+			accelerators = append(accelerators, a)
+		}
+	}
+	m.Accelerators = accelerators
+	return nil
+}
@@ -15,6 +15,8 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
@@ -28,24 +30,57 @@ var Logger = log.New(ioutil.Discard, "", log.LstdFlags)
 
 // Map -
 type Map struct {
-	Size       *Size        `hcl:"size,block"`
-	Nodes      []*Node      `hcl:"node,block"`
-	Connectors []*Connector `hcl:"connector,block"`
+	Meta       *Meta        `hcl:"meta,block" json:"meta"`
+	Size       *Size        `hcl:"size,block" json:"size"`
+	Axes       *Axes        `hcl:"axes,block" json:"axes"`
+	Font       *Fonts       `hcl:"font,block" json:"font"`
+	Theme      *Theme       `hcl:"theme,attr" json:"theme"`
+	Layout     *Layout      `hcl:"layout,block" json:"layout"`
+	Regions    []*Region    `hcl:"region,block" json:"regions"`
+	Nodes      []*Node      `hcl:"node,block" json:"nodes"`
+	Connectors []*Connector `hcl:"connector,block" json:"connectors"`
+	// Accelerators - Accelerator and de-accelerator markers.
+	Accelerators []*Accelerator `hcl:"accelerator,block" json:"accelerators"`
 }
 
 var mapSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "theme"},
+	},
 	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "meta"},
 		{Type: "size"},
+		{Type: "axes"},
+		{Type: "font"},
+		{Type: "theme_def", LabelNames: []string{"name"}},
+		{Type: "layout"},
+		{Type: "region", LabelNames: []string{"id"}},
 		{Type: "node", LabelNames: []string{"id"}},
 		{Type: "connector"},
+		{Type: "accelerator"},
 	},
 }
 
+// Meta - Map title and document metadata.
+type Meta struct {
+	Title    string   `hcl:"title,optional" json:"title"`
+	Subtitle string   `hcl:"subtitle,optional" json:"subtitle"`
+	Author   string   `hcl:"author,optional" json:"author"`
+	Date     string   `hcl:"date,optional" json:"date"`
+	Version  string   `hcl:"version,optional" json:"version"`
+	Tags     []string `hcl:"tags,optional" json:"tags"`
+	Source   string   `hcl:"source,optional" json:"source"`
+}
+
+func (m *Meta) String() string {
+	return fmt.Sprintf("Title='%s', Subtitle='%s', Author='%s', Date='%s', Version='%s', Tags=%v, Source='%s'", m.Title, m.Subtitle, m.Author, m.Date, m.Version, m.Tags, m.Source)
+}
+
 type Size struct {
-	Width    int `hcl:"width,optional"`
-	Height   int `hcl:"height,optional"`
-	Margin   int `hcl:"margin,optional"`
-	FontSize int `hcl:"font_size,optional"`
+	Width    int `hcl:"width,optional" json:"width"`
+	Height   int `hcl:"height,optional" json:"height"`
+	Margin   int `hcl:"margin,optional" json:"margin"`
+	FontSize int `hcl:"font_size,optional" json:"font_size"`
 }
 
 func (s *Size) String() string {
@@ -59,52 +94,253 @@ var sizeDefaults = Size{
 	FontSize: 12,
 }
 
+// Fonts - Base font and per element font overrides.
+// Element fonts inherit unset values from the base font.
+// The base font size defaults to the size block font_size and the family to the theme font_family.
+type Fonts struct {
+	Family    string `hcl:"family,optional" json:"family"`
+	Size      int    `hcl:"size,optional" json:"size"`
+	Weight    string `hcl:"weight,optional" json:"weight"`
+	Node      *Font  `hcl:"node,block" json:"node"`
+	Connector *Font  `hcl:"connector,block" json:"connector"`
+	Axis      *Font  `hcl:"axis,block" json:"axis"`
+	AxisTitle *Font  `hcl:"axis_title,block" json:"axis_title"`
+	Title     *Font  `hcl:"title,block" json:"title"`
+}
+
+func (f *Fonts) String() string {
+	return fmt.Sprintf("family=%s, size=%d, weight=%s, node={%s}, connector={%s}, axis={%s}, axis_title={%s}, title={%s}", f.Family, f.Size, f.Weight, f.Node, f.Connector, f.Axis, f.AxisTitle, f.Title)
+}
+
+// Font -
+type Font struct {
+	Family string `hcl:"family,optional" json:"family"`
+	Size   int    `hcl:"size,optional" json:"size"`
+	Weight string `hcl:"weight,optional" json:"weight"`
+}
+
+func (f *Font) String() string {
+	if f == nil {
+		return ""
+	}
+	return fmt.Sprintf("family=%s, size=%d, weight=%s", f.Family, f.Size, f.Weight)
+}
+
+// fontsWithDefaults - Fills in the unset font values.
+// Element sizes that are not set keep the historic offsets from the base size.
+func fontsWithDefaults(fonts *Fonts, size int, family string) *Fonts {
+	if fonts.Family == "" {
+		fonts.Family = family
+	}
+	if fonts.Size == 0 {
+		fonts.Size = size
+	}
+	if fonts.Weight == "" {
+		fonts.Weight = "normal"
+	}
+	inherit := func(f *Font, family string, sizeOffset int, weight string) *Font {
+		font := Font{}
+		if f != nil {
+			font = *f
+		}
+		if font.Family == "" {
+			font.Family = family
+		}
+		if font.Size == 0 {
+			font.Size = fonts.Size + sizeOffset
+		}
+		if font.Weight == "" {
+			font.Weight = weight
+		}
+		return &font
+	}
+	fonts.Node = inherit(fonts.Node, fonts.Family, 0, fonts.Weight)
+	fonts.Connector = inherit(fonts.Connector, fonts.Family, 0, fonts.Weight)
+	fonts.Axis = inherit(fonts.Axis, fonts.Family, 2, fonts.Weight)
+	fonts.AxisTitle = inherit(fonts.AxisTitle, "serif", 4, "bold")
+	fonts.Title = inherit(fonts.Title, "serif", 6, "bold")
+	return fonts
+}
+
+// Axes - Evolution stage names and axis titles.
+type Axes struct {
+	Preset     string   `hcl:"preset,optional" json:"preset"`
+	Stages     []string `hcl:"stages,optional" json:"stages"`
+	Evolution  string   `hcl:"evolution,optional" json:"evolution"`
+	ValueChain string   `hcl:"value_chain,optional" json:"value_chain"`
+	Visible    string   `hcl:"visible,optional" json:"visible"`
+	Invisible  string   `hcl:"invisible,optional" json:"invisible"`
+}
+
+func (a *Axes) String() string {
+	return fmt.Sprintf("Preset=%s, Stages=%q, Evolution='%s', ValueChain='%s', Visible='%s', Invisible='%s'", a.Preset, a.Stages, a.Evolution, a.ValueChain, a.Visible, a.Invisible)
+}
+
+// Stage - Returns the stage index for the given node evolution.
+// The evolution can be the stage name or the stage ID of any of the presets.
+// For example, "genesis", "novel" and "unmodelled" all refer to the first stage.
+func (a *Axes) Stage(evolution string) (int, bool) {
+	e := strings.ToLower(evolution)
+	for i, stage := range a.Stages {
+		if strings.ToLower(stage) == e {
+			return i, true
+		}
+	}
+	for _, preset := range axesPresetNames {
+		for i, id := range axesPresets[preset].ids {
+			if id == e && i < len(a.Stages) {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+type axesPreset struct {
+	ids    []string
+	stages []string
+}
+
+// axesPresets - Characteristics tables from Simon Wardley's work.
+var axesPresets = map[string]axesPreset{
+	"activity": {
+		ids:    []string{"genesis", "custom", "product", "commodity"},
+		stages: []string{"Genesis", "Custom", "Product (+rental)", "Commodity (+utility)"},
+	},
+	"practice": {
+		ids:    []string{"novel", "emerging", "good", "best"},
+		stages: []string{"Novel", "Emerging", "Good", "Best"},
+	},
+	"data": {
+		ids:    []string{"unmodelled", "divergent", "convergent", "modelled"},
+		stages: []string{"Unmodelled", "Divergent", "Convergent", "Modelled"},
+	},
+	"knowledge": {
+		ids:    []string{"concept", "hypothesis", "theory", "accepted"},
+		stages: []string{"Concept", "Hypothesis", "Theory", "Accepted"},
+	},
+}
+
+// axesPresetNames - Preset lookup order.
+var axesPresetNames = []string{"activity", "practice", "data", "knowledge"}
+
+var axesDefaults = Axes{
+	Preset:     "activity",
+	Stages:     axesPresets["activity"].stages,
+	Evolution:  "Evolution",
+	ValueChain: "Value Chain",
+	Visible:    "Visible",
+	Invisible:  "Invisible",
+}
+
 // Node -
 type Node struct {
-	ID          string `hcl:"id,label"`
-	Label       string `hcl:"label"`
-	Description string `hcl:"description,optional"`
-	X           int
-	Y           int
-	Visibility  int    `hcl:"visibility" cty:"visibility"`
-	Evolution   string `hcl:"evolution"`
-	EvolutionX  int    `hcl:"x" cty:"x"`
-	Fill        string `hcl:"fill,optional"`
-	Color       string `hcl:"color,optional"`
+	ID    string `hcl:"id,label" json:"id"`
+	Label string `hcl:"label" json:"label"`
+	// Type - component, anchor, market or ecosystem.
+	// Anchors, like users or customers, are the roots of the value chain and default to visibility 1.
+	Type string `hcl:"type,optional" json:"type"`
+	// Method - Sourcing method: build, buy or outsource.
+	Method      string `hcl:"method,optional" json:"method"`
+	Description string `hcl:"description,optional" json:"description"`
+	X           int    `json:"pixel_x"`
+	Y           int    `json:"pixel_y"`
+	Stage       int    `json:"stage"`
+	Visibility  int    `hcl:"visibility,optional" cty:"visibility" json:"visibility"`
+	Evolution   string `hcl:"evolution" json:"evolution"`
+	EvolutionX  int    `hcl:"x" cty:"x" json:"x"`
+	Fill        string `hcl:"fill,optional" json:"fill"`
+	Color       string `hcl:"color,optional" json:"color"`
+	// LabelPosition - Pins the label to one side of the node, by default it is placed to avoid overlaps.
+	LabelPosition string `hcl:"label_position,optional" json:"label_position"`
+	// URL - Link for the node, like a runbook or a repository page.
+	URL string `hcl:"url,optional" json:"url"`
+	// Submap - Map file, relative to this one, that details the node.
+	Submap string `hcl:"submap,optional" json:"submap"`
+	// Inertia - Resistance to change, drawn as a bar next to the node.
+	Inertia     *Inertia `hcl:"inertia,block" json:"inertia"`
+	LabelX      int      `json:"label_x"`
+	LabelY      int      `json:"label_y"`
+	LabelAnchor string   `json:"label_anchor"`
 }
 
 func (n *Node) String() string {
-	return fmt.Sprintf("ID=%s, Label='%s', Description='%s', Visibility=%d, X=%d, Fill=%s, Color=%s", n.ID, n.Label, n.Description, n.Visibility, n.EvolutionX, n.Fill, n.Color)
+	return fmt.Sprintf("ID=%s, Label='%s', Type=%s, Method=%s, URL=%s, Submap=%s, Description='%s', Visibility=%d, X=%d, Fill=%s, Color=%s, LabelPosition=%s", n.ID, n.Label, n.Type, n.Method, n.URL, n.Submap, n.Description, n.Visibility, n.EvolutionX, n.Fill, n.Color, n.LabelPosition)
 }
 
+// LabelPositions - Valid node label_position values.
+var LabelPositions = []string{"auto", "right", "left", "top", "bottom", "top-right", "top-left", "bottom-right", "bottom-left"}
+
 var nodeType = cty.Object(map[string]cty.Type{
 	"x":          cty.Number,
 	"visibility": cty.Number,
 })
 
+// Inertia - Node inertia marker.
+type Inertia struct {
+	// Strength - 1 to 3, the width of the bar.
+	Strength int    `hcl:"strength,optional" json:"strength"`
+	Label    string `hcl:"label,optional" json:"label"`
+}
+
+func (i *Inertia) String() string {
+	return fmt.Sprintf("Strength=%d, Label='%s'", i.Strength, i.Label)
+}
+
+// NodeTypes - Valid node type values.
+var NodeTypes = []string{"component", "anchor", "market", "ecosystem"}
+
+// NodeMethods - Valid node method values.
+var NodeMethods = []string{"build", "buy", "outsource"}
+
+// IsAnchor - Reports whether the node is a value chain anchor.
+func (n *Node) IsAnchor() bool {
+	return n.Type == "anchor"
+}
+
+// nodeDefaults - Fill and Color default to the theme colours.
 var nodeDefaults = Node{
-	Fill:  "white",
-	Color: "black",
+	Type: "component",
 }
 
 // Connector -
 type Connector struct {
-	Label string `hcl:"label,optional"`
-	From  string `hcl:"from"`
-	To    string `hcl:"to"`
-	Color string `hcl:"color,optional"`
-	Type  string `hcl:"type,optional"`
+	Label string `hcl:"label,optional" json:"label"`
+	From  string `hcl:"from" json:"from"`
+	To    string `hcl:"to" json:"to"`
+	Color string `hcl:"color,optional" json:"color"`
+	Type  string `hcl:"type,optional" json:"type"`
+	// URL - Link for the connector.
+	URL string `hcl:"url,optional" json:"url"`
+	// Shape - straight, curved or orthogonal, defaults to the layout connector_shape.
+	Shape string `hcl:"shape,optional" json:"shape"`
+	// Label position calculated by the renderer
+	LabelX      int    `json:"label_x"`
+	LabelY      int    `json:"label_y"`
+	LabelAnchor string `json:"label_anchor"`
+	// Route calculated by the renderer.
+	// Straight and orthogonal routes are polylines, curved routes are a quadratic Bézier with the middle point as the control point.
+	Route []Point `json:"route"`
 	// From hcl.Expression `hcl:"from,attr"`
 	// To   hcl.Expression `hcl:"to,attr"`
 }
 
 func (c *Connector) String() string {
-	return fmt.Sprintf("Label='%s', From='%s', To=%s, Color=%s, Type=%s", c.Label, c.From, c.To, c.Color, c.Type)
+	return fmt.Sprintf("Label='%s', From='%s', To=%s, Color=%s, Type=%s, Shape=%s, URL=%s", c.Label, c.From, c.To, c.Color, c.Type, c.Shape, c.URL)
+}
+
+// ConnectorShapes - Valid connector shape values.
+var ConnectorShapes = []string{"straight", "curved", "orthogonal"}
+
+// Point - Position in map coordinates.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
+// connectorDefaults - Color defaults to the theme connector colour.
 var connectorDefaults = Connector{
-	Color: "black",
-	Type:  "normal",
+	Type: "normal",
 }
 
 func ParseHCL(w io.Writer, data []byte, filename string) (*hclparse.Parser, *hcl.File, error) {
@@ -127,8 +363,67 @@ func ParseHCLFile(w io.Writer, filename string) (*hclparse.Parser, *hcl.File, er
 	return parser, f, nil
 }
 
+// ParseJSON - Parses a map written in the HCL JSON syntax.
+func ParseJSON(w io.Writer, data []byte, filename string) (*hclparse.Parser, *hcl.File, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseJSON(data, filename)
+	err := handleDiags(w, parser, diags)
+	if err != nil {
+		return parser, f, fmt.Errorf("failure during input configuration parsing")
+	}
+	return parser, f, nil
+}
+
+func ParseJSONFile(w io.Writer, filename string) (*hclparse.Parser, *hcl.File, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseJSONFile(filename)
+	err := handleDiags(w, parser, diags)
+	if err != nil {
+		return parser, f, fmt.Errorf("failure during input configuration parsing")
+	}
+	return parser, f, nil
+}
+
+// InputFormats - Supported map file formats.
+var InputFormats = []string{"hcl", "json", "yaml"}
+
+// IsInputFormat - Reports whether the format is one of InputFormats.
+func IsInputFormat(format string) bool {
+	return contains(InputFormats, format)
+}
+
+// FileFormat - Returns the map format for the file extension, hcl by default.
+func FileFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "hcl"
+}
+
+// ParseFile - Parses a map file in the given format, see InputFormats.
+// An empty format is selected from the file extension.
+// All formats share the DecodeMap decoding, defaults and validation.
+func ParseFile(w io.Writer, filename, format string) (*hclparse.Parser, *hcl.File, error) {
+	if format == "" {
+		format = FileFormat(filename)
+	}
+	switch format {
+	case "hcl":
+		return ParseHCLFile(w, filename)
+	case "json":
+		return ParseJSONFile(w, filename)
+	case "yaml":
+		return ParseYAMLFile(w, filename)
+	}
+	return nil, nil, fmt.Errorf("unknown input format '%s', must be one of %q", format, InputFormats)
+}
+
+// handleDiags - Writes the diagnostics, warnings included, and returns an error if there are any errors.
 func handleDiags(w io.Writer, parser *hclparse.Parser, diags hcl.Diagnostics) error {
-	if diags.HasErrors() {
+	if len(diags) > 0 {
 		wr := hcl.NewDiagnosticTextWriter(
 			w,              // writer to send messages to
 			parser.Files(), // the parser's file cache, for source snippets
@@ -136,6 +431,8 @@ func handleDiags(w io.Writer, parser *hclparse.Parser, diags hcl.Diagnostics) er
 			true,           // generate colored/highlighted output
 		)
 		wr.WriteDiagnostics(diags)
+	}
+	if diags.HasErrors() {
 		return fmt.Errorf("errors found")
 	}
 	return nil
@@ -150,6 +447,15 @@ func DecodeMap(w io.Writer, parser *hclparse.Parser, f *hcl.File) (*Map, error)
 		return nil, err
 	}
 
+	// Node ranges used for diagnostics after all blocks are decoded.
+	nodeRanges := map[string]hcl.Range{}
+	// Nodes with an explicit visibility.
+	explicitVisibility := map[string]bool{}
+	userThemes := map[string]*Theme{}
+	// Region ranges used for diagnostics after all blocks are decoded.
+	regionRanges := map[*Region]hcl.Range{}
+	acceleratorRanges := map[*Accelerator]hcl.Range{}
+
 	ctx := &hcl.EvalContext{
 		Variables: map[string]cty.Value{},
 		Functions: map[string]function.Function{},
@@ -157,6 +463,79 @@ func DecodeMap(w io.Writer, parser *hclparse.Parser, f *hcl.File) (*Map, error)
 
 	for _, block := range content.Blocks {
 		switch block.Type {
+		case "meta":
+			meta := Meta{}
+			diags := gohcl.DecodeBody(block.Body, ctx, &meta)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			Logger.Printf("Meta: %s\n", &meta)
+			mapDetails.Meta = &meta
+		case "axes":
+			axes := Axes{}
+			diags := gohcl.DecodeBody(block.Body, ctx, &axes)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			diags = axesWithDefaults(&axes, block.DefRange)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			Logger.Printf("Axes: %s\n", &axes)
+			mapDetails.Axes = &axes
+		case "font":
+			font := Fonts{}
+			diags := gohcl.DecodeBody(block.Body, ctx, &font)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			// Defaults are applied once the size block and the theme are known
+			mapDetails.Font = &font
+		case "theme_def":
+			theme := Theme{}
+			diags := gohcl.DecodeBody(block.Body, ctx, &theme)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			theme.Name = block.Labels[0]
+			Logger.Printf("Theme: %s\n", &theme)
+			userThemes[theme.Name] = &theme
+		case "layout":
+			layout := layoutDefaults
+			diags := gohcl.DecodeBody(block.Body, ctx, &layout)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			// Start over, the decoding diagnostics have been written
+			diags = hcl.Diagnostics{}
+			if !contains(ConnectorShapes, layout.ConnectorShape) {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid layout connector_shape",
+					Detail:   fmt.Sprintf("Layout connector_shape '%s' must be one of %q.", layout.ConnectorShape, ConnectorShapes),
+					Subject:  &block.DefRange,
+				})
+			}
+			if !contains(LayoutVisibilities, layout.Visibility) {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid layout visibility",
+					Detail:   fmt.Sprintf("Layout visibility '%s' must be one of %q.", layout.Visibility, LayoutVisibilities),
+					Subject:  &block.DefRange,
+				})
+			}
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			Logger.Printf("Layout: %s\n", &layout)
+			mapDetails.Layout = &layout
 		case "size":
 			size := sizeDefaults
 			diags := gohcl.DecodeBody(block.Body, ctx, &size)
@@ -174,6 +553,14 @@ func DecodeMap(w io.Writer, parser *hclparse.Parser, f *hcl.File) (*Map, error)
 				return mapDetails, err
 			}
 			node.ID = block.Labels[0]
+			nodeRanges[node.ID] = block.DefRange
+			visibility, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+				Attributes: []hcl.AttributeSchema{{Name: "visibility"}},
+			})
+			explicitVisibility[node.ID] = visibility.Attributes["visibility"] != nil
+			if node.IsAnchor() && !explicitVisibility[node.ID] {
+				node.Visibility = 1
+			}
 			mapDetails.Nodes = append(mapDetails.Nodes, &node)
 
 			v, err := gocty.ToCtyValue(node, nodeType)
@@ -193,6 +580,27 @@ func DecodeMap(w io.Writer, parser *hclparse.Parser, f *hcl.File) (*Map, error)
 			}
 			ctx.Variables["node"] = cty.MapVal(m)
 			Logger.Printf("Node: %s\n", &node)
+		case "region":
+			region := regionDefaults
+			diags := gohcl.DecodeBody(block.Body, ctx, &region)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			region.ID = block.Labels[0]
+			regionRanges[&region] = block.DefRange
+			Logger.Printf("Region: %s\n", &region)
+			mapDetails.Regions = append(mapDetails.Regions, &region)
+		case "accelerator":
+			accelerator := acceleratorDefaults
+			diags := gohcl.DecodeBody(block.Body, ctx, &accelerator)
+			err = handleDiags(w, parser, diags)
+			if err != nil {
+				return mapDetails, err
+			}
+			acceleratorRanges[&accelerator] = block.DefRange
+			Logger.Printf("Accelerator: %s\n", &accelerator)
+			mapDetails.Accelerators = append(mapDetails.Accelerators, &accelerator)
 		case "connector":
 			connector := connectorDefaults
 			diags := gohcl.DecodeBody(block.Body, ctx, &connector)
@@ -200,13 +608,211 @@ func DecodeMap(w io.Writer, parser *hclparse.Parser, f *hcl.File) (*Map, error)
 			if err != nil {
 				return mapDetails, err
 			}
+			if connector.Shape != "" && !contains(ConnectorShapes, connector.Shape) {
+				diags = hcl.Diagnostics{&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid connector shape",
+					Detail:   fmt.Sprintf("Connector shape '%s' must be one of %q.", connector.Shape, ConnectorShapes),
+					Subject:  &block.DefRange,
+				}}
+				err = handleDiags(w, parser, diags)
+				return mapDetails, err
+			}
 			Logger.Printf("Connector: %s\n", &connector)
 			mapDetails.Connectors = append(mapDetails.Connectors, &connector)
 		}
 	}
 
 	if mapDetails.Size == nil {
-		mapDetails.Size = &sizeDefaults
+		size := sizeDefaults
+		mapDetails.Size = &size
+	}
+	if mapDetails.Axes == nil {
+		axes := axesDefaults
+		mapDetails.Axes = &axes
+	}
+	if mapDetails.Layout == nil {
+		layout := layoutDefaults
+		mapDetails.Layout = &layout
+	}
+
+	themeName := DefaultTheme
+	var themeRange *hcl.Range
+	if attr, ok := content.Attributes["theme"]; ok {
+		diags := gohcl.DecodeExpression(attr.Expr, ctx, &themeName)
+		err = handleDiags(w, parser, diags)
+		if err != nil {
+			return mapDetails, err
+		}
+		themeRange = &attr.Range
+	}
+	if ThemeOverride != "" {
+		themeName = ThemeOverride
+		themeRange = nil
+	}
+	theme, err := resolveTheme(themeName, userThemes)
+	if err != nil {
+		diags = hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid theme",
+			Detail:   err.Error(),
+			Subject:  themeRange,
+		}}
+		err = handleDiags(w, parser, diags)
+		return mapDetails, err
+	}
+	Logger.Printf("Theme: %s\n", theme)
+	mapDetails.Theme = theme
+
+	if mapDetails.Font == nil {
+		mapDetails.Font = &Fonts{}
+	}
+	fontsWithDefaults(mapDetails.Font, mapDetails.Size.FontSize, theme.FontFamily)
+	Logger.Printf("Font: %s\n", mapDetails.Font)
+
+	for _, node := range mapDetails.Nodes {
+		if node.Fill == "" {
+			node.Fill = theme.NodeFill
+		}
+		if node.Color == "" {
+			node.Color = theme.NodeColor
+		}
+	}
+	for _, connector := range mapDetails.Connectors {
+		if connector.Color == "" {
+			connector.Color = theme.ConnectorColor
+		}
+		if connector.Shape == "" {
+			connector.Shape = mapDetails.Layout.ConnectorShape
+		}
+	}
+
+	diags = hcl.Diagnostics{}
+	for _, node := range mapDetails.Nodes {
+		if !contains(NodeTypes, node.Type) {
+			r := nodeRanges[node.ID]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid node type",
+				Detail:   fmt.Sprintf("Node '%s' type '%s' must be one of %q.", node.ID, node.Type, NodeTypes),
+				Subject:  &r,
+			})
+		}
+		if node.Method != "" && !contains(NodeMethods, node.Method) {
+			r := nodeRanges[node.ID]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid node method",
+				Detail:   fmt.Sprintf("Node '%s' method '%s' must be one of %q.", node.ID, node.Method, NodeMethods),
+				Subject:  &r,
+			})
+		}
+		if node.Inertia != nil {
+			if node.Inertia.Strength == 0 {
+				node.Inertia.Strength = 1
+			}
+			if node.Inertia.Strength < 1 || node.Inertia.Strength > 3 {
+				r := nodeRanges[node.ID]
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid inertia strength",
+					Detail:   fmt.Sprintf("Node '%s' inertia strength %d must be between 1 and 3.", node.ID, node.Inertia.Strength),
+					Subject:  &r,
+				})
+			}
+		}
+		if mapDetails.Layout.Visibility == "manual" && !explicitVisibility[node.ID] && !node.IsAnchor() {
+			r := nodeRanges[node.ID]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing visibility",
+				Detail:   fmt.Sprintf("Node '%s' requires a visibility, or use layout { visibility = \"auto\" } to calculate it from the connectors.", node.ID),
+				Subject:  &r,
+			})
+		}
+		stage, ok := mapDetails.Axes.Stage(node.Evolution)
+		if !ok {
+			r := nodeRanges[node.ID]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown evolution stage",
+				Detail:   fmt.Sprintf("Node '%s' evolution '%s' doesn't match any of the axes stages: %q.", node.ID, node.Evolution, mapDetails.Axes.Stages),
+				Subject:  &r,
+			})
+			continue
+		}
+		node.Stage = stage
+
+		if node.LabelPosition != "" && !contains(LabelPositions, node.LabelPosition) {
+			r := nodeRanges[node.ID]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid label_position",
+				Detail:   fmt.Sprintf("Node '%s' label_position '%s' must be one of %q.", node.ID, node.LabelPosition, LabelPositions),
+				Subject:  &r,
+			})
+		}
+	}
+	nodeIDs := map[string]bool{}
+	for _, node := range mapDetails.Nodes {
+		nodeIDs[node.ID] = true
+	}
+	for _, region := range mapDetails.Regions {
+		if region.Fill == "" {
+			region.Fill = theme.Muted
+		}
+		diags = append(diags, validateRegion(mapDetails, region, nodeIDs, regionRanges[region])...)
+	}
+	for _, accelerator := range mapDetails.Accelerators {
+		diags = append(diags, validateAccelerator(mapDetails, accelerator, nodeIDs, acceleratorRanges[accelerator])...)
+	}
+	if !diags.HasErrors() {
+		diags = append(diags, layoutVisibility(mapDetails, mapDetails.Layout.Visibility == "auto", explicitVisibility, nodeRanges)...)
+	}
+	err = handleDiags(w, parser, diags)
+	if err != nil {
+		return mapDetails, err
 	}
 	return mapDetails, nil
 }
+
+// axesWithDefaults - Fills in the preset stages and the default axis titles.
+func axesWithDefaults(axes *Axes, r hcl.Range) hcl.Diagnostics {
+	if axes.Preset == "" {
+		axes.Preset = axesDefaults.Preset
+	}
+	preset, ok := axesPresets[axes.Preset]
+	if !ok {
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unknown axes preset",
+			Detail:   fmt.Sprintf("Preset '%s' must be one of %q.", axes.Preset, axesPresetNames),
+			Subject:  &r,
+		}}
+	}
+	if len(axes.Stages) == 0 {
+		axes.Stages = append([]string{}, preset.stages...)
+	}
+	if axes.Evolution == "" {
+		axes.Evolution = axesDefaults.Evolution
+	}
+	if axes.ValueChain == "" {
+		axes.ValueChain = axesDefaults.ValueChain
+	}
+	if axes.Visible == "" {
+		axes.Visible = axesDefaults.Visible
+	}
+	if axes.Invisible == "" {
+		axes.Invisible = axesDefaults.Invisible
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
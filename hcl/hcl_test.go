@@ -10,12 +10,25 @@ package hcl
 
 import (
 	"bytes"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
 )
 
+// mapDefaults - Map decoded from an empty file.
+var mapDefaults = Map{
+	Size:   &sizeDefaults,
+	Axes:   &axesDefaults,
+	Font:   fontsWithDefaults(&Fonts{}, sizeDefaults.FontSize, builtinThemes[DefaultTheme].FontFamily),
+	Theme:  func() *Theme { t := builtinThemes[DefaultTheme]; return &t }(),
+	Layout: &layoutDefaults,
+}
+
 func TestDecodeMap(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -25,7 +38,57 @@ func TestDecodeMap(t *testing.T) {
 		{"empty", "", &mapDefaults},
 		{"empty", "size {}", &mapDefaults},
 		{"optional", "size { width = 7 }", &Map{
-			Size: &Size{Width: 7, Height: 768, Margin: 40, FontSize: 12},
+			Size:   &Size{Width: 7, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+		}},
+		{"font", `size {
+				font_size = 10
+			}
+			font {
+				family = "Helvetica"
+				node {
+					size = 8
+				}
+				connector {
+					size   = 7
+					weight = "lighter"
+				}
+				title {
+					family = "Georgia"
+				}
+			}`, &Map{
+			Size: &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 10},
+			Axes: &axesDefaults,
+			Font: &Fonts{
+				Family:    "Helvetica",
+				Size:      10,
+				Weight:    "normal",
+				Node:      &Font{Family: "Helvetica", Size: 8, Weight: "normal"},
+				Connector: &Font{Family: "Helvetica", Size: 7, Weight: "lighter"},
+				Axis:      &Font{Family: "Helvetica", Size: 12, Weight: "normal"},
+				AxisTitle: &Font{Family: "serif", Size: 14, Weight: "bold"},
+				Title:     &Font{Family: "Georgia", Size: 16, Weight: "bold"},
+			},
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+		}},
+		{"meta", `meta {
+				title    = "title"
+				subtitle = "subtitle"
+				author   = "author"
+				date     = "2020-01-01"
+				tags     = ["a", "b"]
+				source   = "https://example.com/map.hcl"
+			}`, &Map{
+			Meta:   &Meta{Title: "title", Subtitle: "subtitle", Author: "author", Date: "2020-01-01", Tags: []string{"a", "b"}, Source: "https://example.com/map.hcl"},
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
 		}},
 		{"node", `node id {
 				label = "label"
@@ -33,8 +96,296 @@ func TestDecodeMap(t *testing.T) {
 				evolution = "custom"
 				x = 1
 			}`, &Map{
-			Size:  &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
-			Nodes: []*Node{{ID: "id", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"axes preset", `axes {
+				preset = "practice"
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "emerging"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &Axes{Preset: "practice", Stages: []string{"Novel", "Emerging", "Good", "Best"}, Evolution: "Evolution", ValueChain: "Value Chain", Visible: "Visible", Invisible: "Invisible"},
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "emerging", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"axes stages", `node id {
+				label = "label"
+				visibility = 1
+				evolution = "Three"
+				x = 1
+			}
+			node id2 {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}
+			axes {
+				stages    = ["One", "Two", "Three"]
+				evolution = "Evolución"
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &Axes{Preset: "activity", Stages: []string{"One", "Two", "Three"}, Evolution: "Evolución", ValueChain: "Value Chain", Visible: "Visible", Invisible: "Invisible"},
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes: []*Node{
+				{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 2, Evolution: "Three", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "id2", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+			},
+		}},
+		{"theme", `theme = "brand"
+			theme_def "brand" {
+				base        = "dark"
+				background  = "navy"
+				font_family = "Helvetica"
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}
+			connector {
+				to = "to"
+				from = "from"
+			}`, &Map{
+			Size: &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes: &axesDefaults,
+			Font: fontsWithDefaults(&Fonts{}, 12, "Helvetica"),
+			Theme: &Theme{Name: "brand", Base: "dark", Background: "navy", Text: "#e0e0e0", Muted: "#a0a0a0", Axis: "#e0e0e0", Grid: "#808080", Halo: "#1e1e1e",
+				NodeFill: "#1e1e1e", NodeColor: "#e0e0e0", ConnectorColor: "#e0e0e0", FontFamily: "Helvetica"},
+			Layout:     mapDefaults.Layout,
+			Nodes:      []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "#1e1e1e", Color: "#e0e0e0"}},
+			Connectors: []*Connector{{To: "to", From: "from", Color: "#e0e0e0", Type: "normal", Shape: "straight"}},
+		}},
+		{"auto visibility", `layout {
+				visibility = "auto"
+			}
+			node user {
+				label = "user"
+				evolution = "custom"
+				x = 1
+			}
+			node a {
+				label = "a"
+				evolution = "custom"
+				x = 1
+			}
+			node b {
+				label = "b"
+				evolution = "custom"
+				x = 1
+			}
+			node pinned {
+				label = "pinned"
+				visibility = 5
+				evolution = "custom"
+				x = 1
+			}
+			node c {
+				label = "c"
+				evolution = "product"
+				x = 1
+			}
+			connector {
+				from = "user"
+				to   = "a"
+			}
+			connector {
+				from = "a"
+				to   = "b"
+			}
+			connector {
+				from = "user"
+				to   = "b"
+			}
+			connector {
+				from = "b"
+				to   = "pinned"
+			}
+			connector {
+				from = "pinned"
+				to   = "c"
+			}
+			connector {
+				from = "b"
+				to   = "c"
+				type = "change"
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: &Layout{Visibility: "auto", ConnectorShape: "straight"},
+			Nodes: []*Node{
+				{ID: "user", Label: "user", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "a", Label: "a", Type: "component", Visibility: 2, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "b", Label: "b", Type: "component", Visibility: 3, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "pinned", Label: "pinned", Type: "component", Visibility: 5, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "c", Label: "c", Type: "component", Visibility: 6, Stage: 2, Evolution: "product", EvolutionX: 1, Fill: "white", Color: "black"},
+			},
+			Connectors: []*Connector{
+				{From: "user", To: "a", Color: "black", Type: "normal", Shape: "straight"},
+				{From: "a", To: "b", Color: "black", Type: "normal", Shape: "straight"},
+				{From: "user", To: "b", Color: "black", Type: "normal", Shape: "straight"},
+				{From: "b", To: "pinned", Color: "black", Type: "normal", Shape: "straight"},
+				{From: "pinned", To: "c", Color: "black", Type: "normal", Shape: "straight"},
+				{From: "b", To: "c", Color: "black", Type: "change", Shape: "straight"},
+			},
+		}},
+		{"anchor", `node user {
+				label = "User"
+				type = "anchor"
+				evolution = "custom"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "user", Label: "User", Type: "anchor", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"market", `node id {
+				label = "label"
+				type = "market"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "market", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"method", `node id {
+				label = "label"
+				method = "buy"
+				visibility = 1
+				evolution = "product"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "component", Method: "buy", Visibility: 1, Stage: 2, Evolution: "product", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"region", `region pioneers {
+				label = "Pioneers"
+				evolution = ["custom", "genesis"]
+				visibility = [2, 4]
+			}
+			region team {
+				nodes = ["id"]
+				fill = "blue"
+				opacity = 0.5
+			}
+			region bounds {
+				evolution = [0.45, 0.1]
+			}
+			region mixed {
+				evolution = ["custom", 0.8]
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Regions: []*Region{
+				{ID: "pioneers", Label: "Pioneers", Evolution: []string{"custom", "genesis"}, Visibility: []int{2, 4}, Fill: "gray", Opacity: 0.2, Bounds: []float64{0, 0.5}},
+				{ID: "team", Nodes: []string{"id"}, Fill: "blue", Opacity: 0.5},
+				{ID: "bounds", Evolution: []string{"0.45", "0.1"}, Fill: "gray", Opacity: 0.2, Bounds: []float64{0.1, 0.45}},
+				{ID: "mixed", Evolution: []string{"custom", "0.8"}, Fill: "gray", Opacity: 0.2, Bounds: []float64{0.25, 0.8}},
+			},
+			Nodes: []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+		}},
+		{"accelerator", `accelerator {
+				label = "Open source"
+				node = "id"
+			}
+			accelerator {
+				label = "Patents"
+				evolution = "genesis"
+				visibility = 2
+				direction = "backward"
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+			Accelerators: []*Accelerator{
+				{Label: "Open source", Node: "id", EvolutionX: 1, Direction: "forward"},
+				{Label: "Patents", Evolution: "genesis", EvolutionX: 1, Visibility: 2, Direction: "backward"},
+			},
+		}},
+		{"inertia and submap", `node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+				submap = "sub/detail.hcl"
+				inertia {
+					label = "Legacy"
+				}
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
+			Nodes:  []*Node{{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black", Submap: "sub/detail.hcl", Inertia: &Inertia{Strength: 1, Label: "Legacy"}}},
+		}},
+		{"connector shape", `layout {
+				connector_shape = "curved"
+			}
+			connector {
+				to = "to"
+				from = "from"
+			}
+			connector {
+				to = "to"
+				from = "from"
+				shape = "orthogonal"
+			}`, &Map{
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: &Layout{Visibility: "manual", ConnectorShape: "curved"},
+			Connectors: []*Connector{
+				{To: "to", From: "from", Color: "black", Type: "normal", Shape: "curved"},
+				{To: "to", From: "from", Color: "black", Type: "normal", Shape: "orthogonal"},
+			},
 		}},
 		{"connector", `connector {
 				label = "label"
@@ -42,7 +393,31 @@ func TestDecodeMap(t *testing.T) {
 				from = "from"
 			}`, &Map{
 			Size:       &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
-			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal"}},
+			Axes:       &axesDefaults,
+			Font:       mapDefaults.Font,
+			Theme:      mapDefaults.Theme,
+			Layout:     mapDefaults.Layout,
+			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal", Shape: "straight"}},
+		}},
+		{"url", `node id {
+				label = "label"
+				url = "https://example.com/runbook"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}
+			connector {
+				to = "id"
+				from = "id"
+				url = "https://example.com/adr/1"
+			}`, &Map{
+			Size:       &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:       &axesDefaults,
+			Font:       mapDefaults.Font,
+			Theme:      mapDefaults.Theme,
+			Layout:     mapDefaults.Layout,
+			Nodes:      []*Node{{ID: "id", Label: "label", Type: "component", URL: "https://example.com/runbook", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"}},
+			Connectors: []*Connector{{To: "id", From: "id", Color: "black", Type: "normal", Shape: "straight", URL: "https://example.com/adr/1"}},
 		}},
 		{"all", `node id {
 				label = "label"
@@ -61,12 +436,16 @@ func TestDecodeMap(t *testing.T) {
 				to = "to"
 				from = "from"
 			}`, &Map{
-			Size: &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
 			Nodes: []*Node{
-				{ID: "id", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
-				{ID: "id2", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "id2", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
 			},
-			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal"}},
+			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal", Shape: "straight"}},
 		}},
 		{"references", `node id {
 				label = "label"
@@ -91,13 +470,17 @@ func TestDecodeMap(t *testing.T) {
 				to = "to"
 				from = "from"
 			}`, &Map{
-			Size: &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Size:   &Size{Width: 1280, Height: 768, Margin: 40, FontSize: 12},
+			Axes:   &axesDefaults,
+			Font:   mapDefaults.Font,
+			Theme:  mapDefaults.Theme,
+			Layout: mapDefaults.Layout,
 			Nodes: []*Node{
-				{ID: "id", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
-				{ID: "id2", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 2, Fill: "white", Color: "black"},
-				{ID: "id3", Label: "label", Visibility: 1, Evolution: "custom", EvolutionX: 3, Fill: "white", Color: "black"},
+				{ID: "id", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 1, Fill: "white", Color: "black"},
+				{ID: "id2", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 2, Fill: "white", Color: "black"},
+				{ID: "id3", Label: "label", Type: "component", Visibility: 1, Stage: 1, Evolution: "custom", EvolutionX: 3, Fill: "white", Color: "black"},
 			},
-			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal"}},
+			Connectors: []*Connector{{Label: "label", To: "to", From: "from", Color: "black", Type: "normal", Shape: "straight"}},
 		}},
 	}
 	for _, test := range tests {
@@ -126,3 +509,459 @@ func TestDecodeMap(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeMapErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unknown preset", `axes {
+				preset = "unknown"
+			}`},
+		{"unknown evolution", `node id {
+				label = "label"
+				visibility = 1
+				evolution = "unknown"
+				x = 1
+			}`},
+		{"unknown theme", `theme = "unknown"`},
+		{"unknown base theme", `theme = "brand"
+			theme_def "brand" {
+				base = "unknown"
+			}`},
+		{"invalid label_position", `node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+				label_position = "middle"
+			}`},
+		{"invalid node type", `node id {
+				label = "label"
+				type = "user"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`},
+		{"invalid node method", `node id {
+				label = "label"
+				method = "rent"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`},
+		{"region without members", `region r {
+				label = "label"
+			}`},
+		{"region with nodes and evolution", `region r {
+				nodes = ["id"]
+				evolution = ["genesis"]
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}`},
+		{"region unknown node", `region r {
+				nodes = ["missing"]
+			}`},
+		{"region evolution out of range", `region r {
+				evolution = [0.2, 1.5]
+			}`},
+		{"region single evolution bound", `region r {
+				evolution = [0.3]
+			}`},
+		{"region empty evolution range", `region r {
+				evolution = [0.3, 0.3]
+			}`},
+		{"region unknown stage", `region r {
+				evolution = ["genesis", "mature"]
+			}`},
+		{"accelerator without position", `accelerator {
+				label = "label"
+			}`},
+		{"accelerator invalid direction", `accelerator {
+				evolution = "custom"
+				visibility = 1
+				direction = "up"
+			}`},
+		{"invalid inertia strength", `node id {
+				label = "label"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+				inertia {
+					strength = 5
+				}
+			}`},
+		{"missing visibility", `node id {
+				label = "label"
+				evolution = "custom"
+				x = 1
+			}`},
+		{"invalid layout visibility", `layout {
+				visibility = "magic"
+			}`},
+		{"invalid connector shape", `connector {
+				to = "to"
+				from = "from"
+				shape = "zigzag"
+			}`},
+		{"invalid layout connector_shape", `layout {
+				connector_shape = "zigzag"
+			}`},
+		{"evolution outside stages", `axes {
+				stages = ["One", "Two"]
+			}
+			node id {
+				label = "label"
+				visibility = 1
+				evolution = "product"
+				x = 1
+			}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			parser, f, err := ParseHCL(buf, []byte(test.input), "test.hcl")
+			if err != nil {
+				t.Fatalf("%s\n%s\n", err, buf.String())
+			}
+			_, err = DecodeMap(buf, parser, f)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if buf.String() == "" {
+				t.Errorf("expected diagnostics output")
+			}
+		})
+	}
+}
+
+func TestVisibilityWarnings(t *testing.T) {
+	nodes := `
+		node a {
+			label = "a"
+			visibility = 3
+			evolution = "custom"
+			x = 1
+		}
+		node b {
+			label = "b"
+			visibility = 1
+			evolution = "custom"
+			x = 1
+		}
+		connector {
+			from = "a"
+			to   = "b"
+		}
+		connector {
+			from = "c"
+			to   = "d"
+		}
+		connector {
+			from = "d"
+			to   = "c"
+		}`
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"auto", `layout {
+				visibility = "auto"
+			}
+			node c {
+				label = "c"
+				evolution = "custom"
+				x = 1
+			}
+			node d {
+				label = "d"
+				evolution = "custom"
+				x = 1
+			}` + nodes, []string{"Visibility contradicts dependency", "Dependency cycle"}},
+		{"manual", `node c {
+				label = "c"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}
+			node d {
+				label = "d"
+				visibility = 2
+				evolution = "custom"
+				x = 1
+			}` + nodes, []string{"Visibility contradicts dependency", "Dependency cycle"}},
+		{"manual in order", `node a {
+				label = "a"
+				visibility = 1
+				evolution = "custom"
+				x = 1
+			}
+			node b {
+				label = "b"
+				visibility = 2
+				evolution = "custom"
+				x = 1
+			}
+			connector {
+				from = "a"
+				to   = "b"
+			}`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			parser, f, err := ParseHCL(buf, []byte(test.input), "test.hcl")
+			if err != nil {
+				t.Fatalf("%s\n%s\n", err, buf.String())
+			}
+			_, err = DecodeMap(buf, parser, f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s\n%s", err, buf.String())
+			}
+			for _, expected := range test.expected {
+				// Each warning is written once
+				if n := strings.Count(buf.String(), expected); n != 1 {
+					t.Errorf("expected warning '%s' once, got %d in: %s", expected, n, buf.String())
+				}
+			}
+			if test.expected == nil && buf.Len() != 0 {
+				t.Errorf("unexpected warnings: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestFocus(t *testing.T) {
+	input := `node a {
+			label = "a"
+			visibility = 1
+			evolution = "custom"
+			x = 1
+		}
+		node b {
+			label = "b"
+			visibility = 2
+			evolution = "custom"
+			x = 1
+		}
+		node c {
+			label = "c"
+			visibility = 3
+			evolution = "custom"
+			x = 1
+		}
+		node c2 {
+			label = "c2"
+			visibility = 3
+			evolution = "product"
+			x = 1
+		}
+		node d {
+			label = "d"
+			visibility = 3
+			evolution = "genesis"
+			x = 1
+		}
+		connector {
+			from = "a"
+			to   = "b"
+		}
+		connector {
+			from = "b"
+			to   = "c"
+		}
+		connector {
+			from = "d"
+			to   = "c"
+		}
+		connector {
+			from = "c"
+			to   = "c2"
+			type = "change"
+		}
+		region r {
+			nodes = ["a", "d"]
+		}
+		accelerator {
+			node = "d"
+		}`
+	tests := []struct {
+		name         string
+		node         string
+		depth        int
+		direction    string
+		nodes        []string
+		connectors   int
+		regions      int
+		accelerators int
+		err          string
+	}{
+		{"down", "a", 0, "down", []string{"a", "b", "c", "c2"}, 3, 1, 0, ""},
+		{"depth", "a", 1, "down", []string{"a", "b"}, 1, 1, 0, ""},
+		{"up", "c", 0, "up", []string{"a", "b", "c", "c2", "d"}, 4, 1, 1, ""},
+		{"both", "b", 1, "both", []string{"a", "b", "c", "c2"}, 3, 1, 0, ""},
+		{"leaf", "d", 0, "up", []string{"d"}, 0, 1, 1, ""},
+		{"unknown node", "x", 0, "down", nil, 0, 0, 0, "focus node 'x' doesn't exist"},
+		{"invalid direction", "a", 0, "sideways", nil, 0, 0, 0, "focus direction 'sideways'"},
+		{"invalid depth", "a", -1, "down", nil, 0, 0, 0, "focus depth must be 0 or greater"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			parser, f, err := ParseHCL(buf, []byte(input), "test.hcl")
+			if err != nil {
+				t.Fatalf("%s\n%s\n", err, buf.String())
+			}
+			m, err := DecodeMap(buf, parser, f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s\n%s", err, buf.String())
+			}
+			err = m.Focus(test.node, test.depth, test.direction)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error '%s', got: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			nodes := []string{}
+			for _, n := range m.Nodes {
+				nodes = append(nodes, n.ID)
+			}
+			if !reflect.DeepEqual(nodes, test.nodes) {
+				t.Errorf("expected nodes %v, got %v", test.nodes, nodes)
+			}
+			if len(m.Connectors) != test.connectors {
+				t.Errorf("expected %d connectors, got %d", test.connectors, len(m.Connectors))
+			}
+			if len(m.Regions) != test.regions {
+				t.Errorf("expected %d regions, got %d", test.regions, len(m.Regions))
+			}
+			if len(m.Accelerators) != test.accelerators {
+				t.Errorf("expected %d accelerators, got %d", test.accelerators, len(m.Accelerators))
+			}
+		})
+	}
+}
+
+func TestInputFormats(t *testing.T) {
+	inputs := []struct {
+		name  string
+		parse func(w io.Writer, data []byte, filename string) (*hclparse.Parser, *hcl.File, error)
+		input string
+	}{
+		{"hcl", ParseHCL, `meta {
+				title = "Map"
+				date  = "2020-01-02"
+			}
+			node user {
+				label = "User"
+				type = "anchor"
+				evolution = "custom"
+				x = 2
+			}
+			node web {
+				label = "Web"
+				visibility = 2
+				evolution = "product"
+				x = 1
+				inertia {
+					strength = 2
+				}
+			}
+			connector {
+				from = "user"
+				to   = "web"
+			}
+			connector {
+				from  = "web"
+				to    = "web"
+				label = "self"
+			}
+			region data {
+				nodes = ["web"]
+			}
+			theme = "brand"
+			theme_def "brand" {
+				base      = "dark"
+				node_fill = "navy"
+			}`},
+		{"json", ParseJSON, `{
+				"meta": {"title": "Map", "date": "2020-01-02"},
+				"node": {
+					"user": {"label": "User", "type": "anchor", "evolution": "custom", "x": 2},
+					"web": {"label": "Web", "visibility": 2, "evolution": "product", "x": 1, "inertia": {"strength": 2}}
+				},
+				"connector": [
+					{"from": "user", "to": "web"},
+					{"from": "web", "to": "web", "label": "self"}
+				],
+				"region": {"data": {"nodes": ["web"]}},
+				"theme": "brand",
+				"theme_def": {"brand": {"base": "dark", "node_fill": "navy"}}
+			}`},
+		{"yaml", ParseYAML, `
+meta:
+  title: Map
+  date: 2020-01-02
+node:
+  user:
+    label: User
+    type: anchor
+    evolution: custom
+    x: 2
+  web:
+    label: Web
+    visibility: 2
+    evolution: product
+    x: 1
+    inertia:
+      strength: 2
+connector:
+  - from: user
+    to: web
+  - from: web
+    to: web
+    label: self
+region:
+  data:
+    nodes: [web]
+theme: brand
+theme_def:
+  brand:
+    base: dark
+    node_fill: navy
+`},
+	}
+	var expected *Map
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			parser, f, err := input.parse(buf, []byte(input.input), "test."+input.name)
+			if err != nil {
+				t.Fatalf("%s\n%s\n", err, buf.String())
+			}
+			m, err := DecodeMap(buf, parser, f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s\n%s", err, buf.String())
+			}
+			if m.Theme.Name != "brand" || m.Theme.NodeFill != "navy" {
+				t.Errorf("expected the brand theme, got %s", m.Theme)
+			}
+			if expected == nil {
+				expected = m
+				return
+			}
+			if !reflect.DeepEqual(m, expected) {
+				t.Errorf("expected %s, got %s\n", spew.Sdump(expected), spew.Sdump(m))
+			}
+		})
+	}
+}
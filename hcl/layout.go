@@ -0,0 +1,174 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Layout - Map layout options.
+type Layout struct {
+	// Visibility - manual or auto.
+	// With auto, nodes without an explicit visibility get the depth of the node in the dependency graph.
+	Visibility string `hcl:"visibility,optional" json:"visibility"`
+	// ConnectorShape - Default connector shape.
+	ConnectorShape string `hcl:"connector_shape,optional" json:"connector_shape"`
+}
+
+func (l *Layout) String() string {
+	return fmt.Sprintf("Visibility=%s, ConnectorShape=%s", l.Visibility, l.ConnectorShape)
+}
+
+// LayoutVisibilities - Valid layout visibility values.
+var LayoutVisibilities = []string{"manual", "auto"}
+
+var layoutDefaults = Layout{
+	Visibility:     "manual",
+	ConnectorShape: "straight",
+}
+
+// IsDependency - Reports whether the connector is a value chain dependency.
+// Change connectors show evolution movement rather than a dependency in the value chain.
+func (c *Connector) IsDependency() bool {
+	return c.Type != "change" && c.Type != "change-inertia"
+}
+
+// dependencyGraph - Node dependencies from the connectors.
+// Edges go from the dependent node to its dependency, from the top of the value chain down.
+type dependencyGraph struct {
+	nodes map[string]*Node
+	// out - Dependencies of a node.
+	out map[string][]string
+	// in - Dependents of a node.
+	in map[string][]string
+}
+
+func newDependencyGraph(m *Map) *dependencyGraph {
+	g := &dependencyGraph{
+		nodes: map[string]*Node{},
+		out:   map[string][]string{},
+		in:    map[string][]string{},
+	}
+	for _, n := range m.Nodes {
+		g.nodes[n.ID] = n
+	}
+	for _, c := range m.Connectors {
+		// A node can't be above or below itself
+		if !c.IsDependency() || c.From == c.To {
+			continue
+		}
+		if _, ok := g.nodes[c.From]; !ok {
+			continue
+		}
+		if _, ok := g.nodes[c.To]; !ok {
+			continue
+		}
+		g.out[c.From] = append(g.out[c.From], c.To)
+		g.in[c.To] = append(g.in[c.To], c.From)
+	}
+	return g
+}
+
+// order - Returns the node IDs in topological order, dependents before their dependencies.
+// Edges that close a cycle are ignored and returned so they can be reported.
+func (g *dependencyGraph) order(ids []string) ([]string, [][2]string) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	cycles := [][2]string{}
+	postorder := []string{}
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		for _, dep := range g.out[id] {
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				cycles = append(cycles, [2]string{id, dep})
+			}
+		}
+		state[id] = done
+		postorder = append(postorder, id)
+	}
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	order := make([]string, len(postorder))
+	for i, id := range postorder {
+		order[len(postorder)-1-i] = id
+	}
+	return order, cycles
+}
+
+// layoutVisibility - With auto, sets the visibility of the nodes without an explicit one to their longest path depth from the top of the value chain.
+// Explicit visibilities are kept as pins, anchors without one are pinned to the top of the value chain.
+// In both modes returns warnings for dependency cycles and for explicit values that place a dependency above its dependent.
+func layoutVisibility(m *Map, auto bool, explicit map[string]bool, nodeRanges map[string]hcl.Range) hcl.Diagnostics {
+	diags := hcl.Diagnostics{}
+	g := newDependencyGraph(m)
+	ids := []string{}
+	for _, n := range m.Nodes {
+		ids = append(ids, n.ID)
+	}
+	order, cycles := g.order(ids)
+	ignored := map[[2]string]bool{}
+	for _, c := range cycles {
+		ignored[c] = true
+		r := nodeRanges[c[0]]
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Dependency cycle",
+			Detail:   fmt.Sprintf("Connector from '%s' to '%s' closes a dependency cycle, it is ignored for the visibility layout.", c[0], c[1]),
+			Subject:  &r,
+		})
+	}
+
+	for _, id := range order {
+		n := g.nodes[id]
+		if !auto || explicit[id] || n.IsAnchor() {
+			continue
+		}
+		n.Visibility = 1
+		for _, dependent := range g.in[id] {
+			if ignored[[2]string{dependent, id}] {
+				continue
+			}
+			if v := g.nodes[dependent].Visibility + 1; v > n.Visibility {
+				n.Visibility = v
+			}
+		}
+	}
+
+	for _, id := range order {
+		for _, dep := range g.out[id] {
+			a, b := g.nodes[id], g.nodes[dep]
+			if ignored[[2]string{id, dep}] || !(explicit[id] || explicit[dep] || a.IsAnchor() || b.IsAnchor()) {
+				continue
+			}
+			if b.Visibility < a.Visibility {
+				r := nodeRanges[dep]
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagWarning,
+					Summary:  "Visibility contradicts dependency",
+					Detail:   fmt.Sprintf("Node '%s' visibility %d is above the visibility %d of '%s' that depends on it.", dep, b.Visibility, a.Visibility, id),
+					Subject:  &r,
+				})
+			}
+		}
+	}
+	return diags
+}
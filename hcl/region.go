@@ -0,0 +1,120 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Region - Shaded area of the map, like Pioneers, Settlers and Town Planners ownership or a bounded context.
+// Defined either by a rectangle of evolution and visibility bounds or by the nodes it surrounds.
+type Region struct {
+	ID    string `hcl:"id,label" json:"id"`
+	Label string `hcl:"label,optional" json:"label"`
+	// Nodes - Member node IDs, the region is their padded convex hull.
+	Nodes []string `hcl:"nodes,optional" json:"nodes"`
+	// Evolution - Left and right bounds of the rectangle, either evolution between 0 and 1 or stage names covering the whole stage.
+	Evolution []string `hcl:"evolution,optional" json:"evolution"`
+	// Visibility - Top and bottom visibility covered by the rectangle, the full height by default.
+	Visibility []int   `hcl:"visibility,optional" json:"visibility"`
+	Fill       string  `hcl:"fill,optional" json:"fill"`
+	Opacity    float64 `hcl:"opacity,optional" json:"opacity"`
+	// Bounds - Left and right evolution of the rectangle, between 0 and 1.
+	Bounds []float64 `json:"bounds"`
+}
+
+func (r *Region) String() string {
+	return fmt.Sprintf("ID=%s, Label='%s', Nodes=%q, Evolution=%q, Visibility=%v, Fill=%s, Opacity=%g, Bounds=%v",
+		r.ID, r.Label, r.Nodes, r.Evolution, r.Visibility, r.Fill, r.Opacity, r.Bounds)
+}
+
+// regionDefaults - Fill defaults to the theme muted colour.
+var regionDefaults = Region{
+	Opacity: 0.2,
+}
+
+// validateRegion - Checks the region definition and resolves its evolution bounds.
+func validateRegion(m *Map, region *Region, nodes map[string]bool, r hcl.Range) hcl.Diagnostics {
+	diags := hcl.Diagnostics{}
+	invalid := func(detail string) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid region",
+			Detail:   detail,
+			Subject:  &r,
+		})
+	}
+	if (len(region.Nodes) == 0) == (len(region.Evolution) == 0) {
+		invalid(fmt.Sprintf("Region '%s' must set either nodes or evolution.", region.ID))
+		return diags
+	}
+	for _, id := range region.Nodes {
+		if !nodes[id] {
+			invalid(fmt.Sprintf("Region '%s' node '%s' doesn't exist.", region.ID, id))
+		}
+	}
+	if len(region.Nodes) > 0 && len(region.Visibility) > 0 {
+		invalid(fmt.Sprintf("Region '%s' visibility only applies to evolution regions.", region.ID))
+	}
+	if len(region.Evolution) > 2 {
+		invalid(fmt.Sprintf("Region '%s' evolution must list the left and right bound.", region.ID))
+	}
+	// A stage name covers the whole stage, a number is a single evolution
+	left, right := 1.0, 0.0
+	stages := float64(len(m.Axes.Stages))
+	for _, e := range region.Evolution {
+		lo, hi := 0.0, 0.0
+		if v, err := strconv.ParseFloat(e, 64); err == nil {
+			if v < 0 || v > 1 {
+				invalid(fmt.Sprintf("Region '%s' evolution %s must be between 0 and 1.", region.ID, e))
+				continue
+			}
+			if len(region.Evolution) == 1 {
+				invalid(fmt.Sprintf("Region '%s' evolution %s must come with a right bound.", region.ID, e))
+				continue
+			}
+			lo, hi = v, v
+		} else {
+			stage, ok := m.Axes.Stage(e)
+			if !ok {
+				invalid(fmt.Sprintf("Region '%s' evolution '%s' doesn't match any of the axes stages: %q.", region.ID, e, m.Axes.Stages))
+				continue
+			}
+			lo, hi = float64(stage)/stages, float64(stage+1)/stages
+		}
+		if lo < left {
+			left = lo
+		}
+		if hi > right {
+			right = hi
+		}
+	}
+	if len(region.Evolution) > 0 && !diags.HasErrors() {
+		if left >= right {
+			invalid(fmt.Sprintf("Region '%s' evolution bounds %q must cover some evolution.", region.ID, region.Evolution))
+		} else {
+			region.Bounds = []float64{left, right}
+		}
+	}
+	if len(region.Visibility) > 2 {
+		invalid(fmt.Sprintf("Region '%s' visibility must list the top and bottom visibility.", region.ID))
+	}
+	for _, v := range region.Visibility {
+		if v < 1 {
+			invalid(fmt.Sprintf("Region '%s' visibility %d must be 1 or greater.", region.ID, v))
+		}
+	}
+	if region.Opacity < 0 || region.Opacity > 1 {
+		invalid(fmt.Sprintf("Region '%s' opacity %g must be between 0 and 1.", region.ID, region.Opacity))
+	}
+	return diags
+}
@@ -0,0 +1,145 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Theme - Map colours and default font family.
+// User defined themes inherit unset values from their base theme.
+type Theme struct {
+	Name           string `hcl:"name,label" json:"name"`
+	Base           string `hcl:"base,optional" json:"base"`
+	Background     string `hcl:"background,optional" json:"background"`
+	Text           string `hcl:"text,optional" json:"text"`
+	Muted          string `hcl:"muted,optional" json:"muted"`
+	Axis           string `hcl:"axis,optional" json:"axis"`
+	Grid           string `hcl:"grid,optional" json:"grid"`
+	Halo           string `hcl:"halo,optional" json:"halo"`
+	NodeFill       string `hcl:"node_fill,optional" json:"node_fill"`
+	NodeColor      string `hcl:"node_color,optional" json:"node_color"`
+	ConnectorColor string `hcl:"connector_color,optional" json:"connector_color"`
+	FontFamily     string `hcl:"font_family,optional" json:"font_family"`
+}
+
+func (t *Theme) String() string {
+	return fmt.Sprintf("Name=%s, Base=%s, Background=%s, Text=%s, Muted=%s, Axis=%s, Grid=%s, Halo=%s, NodeFill=%s, NodeColor=%s, ConnectorColor=%s, FontFamily=%s",
+		t.Name, t.Base, t.Background, t.Text, t.Muted, t.Axis, t.Grid, t.Halo, t.NodeFill, t.NodeColor, t.ConnectorColor, t.FontFamily)
+}
+
+// ThemeOverride - When set, selects the theme to use instead of the one selected in the map.
+var ThemeOverride string
+
+// DefaultTheme - Theme used when the map doesn't select one.
+const DefaultTheme = "light"
+
+// builtinThemes - Themes available to every map.
+var builtinThemes = map[string]Theme{
+	"light": {
+		Name:           "light",
+		Background:     "white",
+		Text:           "black",
+		Muted:          "gray",
+		Axis:           "black",
+		Grid:           "gray",
+		Halo:           "white",
+		NodeFill:       "white",
+		NodeColor:      "black",
+		ConnectorColor: "black",
+		FontFamily:     "sans-serif",
+	},
+	"dark": {
+		Name:           "dark",
+		Background:     "#1e1e1e",
+		Text:           "#e0e0e0",
+		Muted:          "#a0a0a0",
+		Axis:           "#e0e0e0",
+		Grid:           "#808080",
+		Halo:           "#1e1e1e",
+		NodeFill:       "#1e1e1e",
+		NodeColor:      "#e0e0e0",
+		ConnectorColor: "#e0e0e0",
+		FontFamily:     "sans-serif",
+	},
+	"high-contrast": {
+		Name:           "high-contrast",
+		Background:     "black",
+		Text:           "white",
+		Muted:          "white",
+		Axis:           "white",
+		Grid:           "yellow",
+		Halo:           "black",
+		NodeFill:       "black",
+		NodeColor:      "yellow",
+		ConnectorColor: "white",
+		FontFamily:     "sans-serif",
+	},
+	"print": {
+		Name:           "print",
+		Background:     "white",
+		Text:           "black",
+		Muted:          "black",
+		Axis:           "black",
+		Grid:           "#999999",
+		Halo:           "white",
+		NodeFill:       "white",
+		NodeColor:      "black",
+		ConnectorColor: "black",
+		FontFamily:     "serif",
+	},
+}
+
+// BuiltinThemes - Returns the sorted list of built-in theme names.
+func BuiltinThemes() []string {
+	names := []string{}
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveTheme - Returns the named theme with the unset values filled from its base theme.
+func resolveTheme(name string, userThemes map[string]*Theme) (*Theme, error) {
+	if t, ok := builtinThemes[name]; ok {
+		return &t, nil
+	}
+	user, ok := userThemes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme '%s', must be one of %q or a user defined theme", name, BuiltinThemes())
+	}
+	baseName := user.Base
+	if baseName == "" {
+		baseName = DefaultTheme
+	}
+	base, ok := builtinThemes[baseName]
+	if !ok {
+		return nil, fmt.Errorf("unknown base theme '%s' for theme '%s', must be one of %q", baseName, name, BuiltinThemes())
+	}
+	t := *user
+	t.Base = baseName
+	fill := func(v *string, d string) {
+		if *v == "" {
+			*v = d
+		}
+	}
+	fill(&t.Background, base.Background)
+	fill(&t.Text, base.Text)
+	fill(&t.Muted, base.Muted)
+	fill(&t.Axis, base.Axis)
+	fill(&t.Grid, base.Grid)
+	fill(&t.Halo, base.Halo)
+	fill(&t.NodeFill, base.NodeFill)
+	fill(&t.NodeColor, base.NodeColor)
+	fill(&t.ConnectorColor, base.ConnectorColor)
+	fill(&t.FontFamily, base.FontFamily)
+	return &t, nil
+}
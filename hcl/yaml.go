@@ -0,0 +1,127 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAML - Parses a map written in YAML.
+// The YAML document follows the HCL JSON syntax: blocks are keys, labelled blocks are nested by label and repeated unlabelled blocks are lists.
+// The document is converted to JSON, in the same key order, and parsed with the HCL JSON parser.
+// Diagnostics show the converted JSON.
+func ParseYAML(w io.Writer, data []byte, filename string) (*hclparse.Parser, *hcl.File, error) {
+	parser := hclparse.NewParser()
+	doc := yaml.Node{}
+	err := yaml.Unmarshal(data, &doc)
+	if err == nil {
+		var b bytes.Buffer
+		err = yamlToJSON(&b, &doc)
+		if err == nil {
+			var indented bytes.Buffer
+			err = json.Indent(&indented, b.Bytes(), "", "  ")
+			data = indented.Bytes()
+		}
+	}
+	if err != nil {
+		handleDiags(w, parser, hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid YAML",
+			Detail:   fmt.Sprintf("%s: %s", filename, err),
+		}})
+		return parser, nil, fmt.Errorf("failure during input configuration parsing")
+	}
+	Logger.Printf("YAML as JSON: %s\n", data)
+	f, diags := parser.ParseJSON(data, filename)
+	err = handleDiags(w, parser, diags)
+	if err != nil {
+		return parser, f, fmt.Errorf("failure during input configuration parsing")
+	}
+	return parser, f, nil
+}
+
+func ParseYAMLFile(w io.Writer, filename string) (*hclparse.Parser, *hcl.File, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseYAML(w, data, filename)
+}
+
+// yamlToJSON - Writes the YAML node as JSON, mapping keys keep their order.
+func yamlToJSON(b *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		return yamlToJSON(b, n.Content[0])
+	case yaml.AliasNode:
+		return yamlToJSON(b, n.Alias)
+	case yaml.MappingNode:
+		b.WriteString("{")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			if i > 0 {
+				b.WriteString(",")
+			}
+			k, _ := json.Marshal(key.Value)
+			b.Write(k)
+			b.WriteString(":")
+			err := yamlToJSON(b, n.Content[i+1])
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+		return nil
+	case yaml.SequenceNode:
+		b.WriteString("[")
+		for i, c := range n.Content {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			err := yamlToJSON(b, c)
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+		return nil
+	}
+	var v interface{}
+	switch n.ShortTag() {
+	case "!!str", "!!timestamp", "!!binary":
+		// Kept as written, dates stay as plain strings
+		v = n.Value
+	default:
+		err := n.Decode(&v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+	}
+	s, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	b.Write(s)
+	return nil
+}
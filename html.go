@@ -0,0 +1,276 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// htmlStyle - Page styles, colours come from the map theme.
+const htmlStyle = `
+body { margin: 0; display: flex; flex-direction: column; height: 100vh; background: %[1]s; color: %[2]s; font-family: %[3]s; }
+.wm-toolbar { display: flex; gap: 0.5em; align-items: center; padding: 0.4em 0.8em; border-bottom: 1px solid %[4]s; }
+.wm-toolbar input { flex: 0 1 20em; padding: 0.2em 0.4em; }
+.wm-toolbar button { min-width: 2em; }
+.wm-main { display: flex; flex: 1; min-height: 0; }
+.wm-viewport { flex: 1; overflow: hidden; cursor: grab; }
+.wm-viewport.wm-viewport--panning { cursor: grabbing; }
+.wm-viewport > svg { width: 100%%; height: 100%%; }
+.wm-sidebar { display: none; width: 22em; overflow: auto; padding: 0.5em 1em; border-left: 1px solid %[4]s; font-size: 14px; }
+.wm-sidebar.wm-sidebar--open { display: block; }
+.wm-popover { position: fixed; display: none; max-width: 24em; padding: 0.5em 0.8em; background: %[1]s; color: %[2]s; border: 1px solid %[4]s; border-radius: 4px; box-shadow: 0 2px 8px rgba(0,0,0,0.3); font-size: 14px; z-index: 10; pointer-events: none; }
+.wm-popover.wm-popover--open { display: block; }
+.wm-popover h1, .wm-popover h2, .wm-popover h3, .wm-popover h4, .wm-popover h5, .wm-popover h6,
+.wm-sidebar h1, .wm-sidebar h2, .wm-sidebar h3, .wm-sidebar h4, .wm-sidebar h5, .wm-sidebar h6 { font-size: 1.1em; margin: 0.3em 0; }
+.wm-popover p, .wm-popover ul, .wm-popover ol, .wm-sidebar p, .wm-sidebar ul, .wm-sidebar ol { margin: 0.3em 0; }
+.wm-popover code, .wm-sidebar code { font-family: monospace; }
+.wm-popover a, .wm-sidebar a { color: inherit; }
+[data-node] { cursor: pointer; }
+[data-node], [data-from] { transition: opacity 0.15s; }
+.wm-dim { opacity: 0.15; }
+.wm-match .wm-node__circle, .wm-match circle { stroke-width: 3; }
+`
+
+// htmlScript - Zoom and pan, search, dependency highlighting, description popover and sidebar.
+const htmlScript = `
+(function () {
+  var data = JSON.parse(document.getElementById("wm-data").textContent);
+  var viewport = document.querySelector(".wm-viewport");
+  var svg = viewport.querySelector("svg");
+  var popover = document.getElementById("wm-popover");
+  var sidebar = document.getElementById("wm-sidebar");
+  var search = document.getElementById("wm-search");
+  var nodes = {}, byID = {};
+  data.nodes.forEach(function (n) { byID[n.id] = n; });
+  document.querySelectorAll("[data-node]").forEach(function (el) { nodes[el.getAttribute("data-node")] = el; });
+  var connectors = Array.prototype.slice.call(document.querySelectorAll("[data-from]"));
+
+  // Zoom and pan
+  var width = parseFloat(svg.getAttribute("width")), height = parseFloat(svg.getAttribute("height"));
+  var view = { x: 0, y: 0, w: width, h: height };
+  function apply() { svg.setAttribute("viewBox", [view.x, view.y, view.w, view.h].join(" ")); }
+  function zoom(factor, cx, cy) {
+    var r = svg.getBoundingClientRect();
+    var scale = Math.max(view.w / r.width, view.h / r.height);
+    var px = view.x + (cx - r.left) * scale - (view.w - r.width * scale) / 2;
+    var py = view.y + (cy - r.top) * scale - (view.h - r.height * scale) / 2;
+    view.x = px - (px - view.x) * factor;
+    view.y = py - (py - view.y) * factor;
+    view.w *= factor;
+    view.h *= factor;
+    apply();
+  }
+  function center(factor) {
+    var r = svg.getBoundingClientRect();
+    zoom(factor, r.left + r.width / 2, r.top + r.height / 2);
+  }
+  svg.removeAttribute("width");
+  svg.removeAttribute("height");
+  apply();
+  viewport.addEventListener("wheel", function (e) {
+    e.preventDefault();
+    zoom(e.deltaY > 0 ? 1.1 : 1 / 1.1, e.clientX, e.clientY);
+  }, { passive: false });
+  var pan = null;
+  viewport.addEventListener("pointerdown", function (e) {
+    if (e.button !== 0 || e.target.closest("[data-node], a")) { return; }
+    var r = svg.getBoundingClientRect();
+    pan = { x: e.clientX, y: e.clientY, vx: view.x, vy: view.y, scale: Math.max(view.w / r.width, view.h / r.height) };
+    viewport.classList.add("wm-viewport--panning");
+    viewport.setPointerCapture(e.pointerId);
+  });
+  viewport.addEventListener("pointermove", function (e) {
+    if (!pan) { return; }
+    view.x = pan.vx - (e.clientX - pan.x) * pan.scale;
+    view.y = pan.vy - (e.clientY - pan.y) * pan.scale;
+    apply();
+  });
+  viewport.addEventListener("pointerup", function () {
+    pan = null;
+    viewport.classList.remove("wm-viewport--panning");
+  });
+  document.getElementById("wm-zoom-in").addEventListener("click", function () { center(1 / 1.25); });
+  document.getElementById("wm-zoom-out").addEventListener("click", function () { center(1.25); });
+  document.getElementById("wm-zoom-reset").addEventListener("click", function () {
+    view = { x: 0, y: 0, w: width, h: height };
+    apply();
+  });
+
+  // Highlighting
+  function closure(id, key, other) {
+    var seen = {};
+    var queue = [id];
+    while (queue.length > 0) {
+      var current = queue.shift();
+      data.edges.forEach(function (e) {
+        if (e[key] === current && !seen[e[other]]) {
+          seen[e[other]] = true;
+          queue.push(e[other]);
+        }
+      });
+    }
+    return seen;
+  }
+  function highlight(keep) {
+    Object.keys(nodes).forEach(function (id) {
+      nodes[id].classList.toggle("wm-dim", keep !== null && !keep[id]);
+    });
+    connectors.forEach(function (c) {
+      var from = c.getAttribute("data-from"), to = c.getAttribute("data-to");
+      c.classList.toggle("wm-dim", keep !== null && !(keep[from] && keep[to]));
+    });
+  }
+  function dependencies(id) {
+    var keep = closure(id, "from", "to");
+    var up = closure(id, "to", "from");
+    Object.keys(up).forEach(function (k) { keep[k] = true; });
+    keep[id] = true;
+    return keep;
+  }
+  function matches() {
+    var q = search.value.trim().toLowerCase();
+    if (q === "") { return null; }
+    var keep = {};
+    data.nodes.forEach(function (n) {
+      if (n.label.toLowerCase().indexOf(q) >= 0 || n.id.toLowerCase().indexOf(q) >= 0) { keep[n.id] = true; }
+    });
+    return keep;
+  }
+  function reset() {
+    var keep = matches();
+    highlight(keep);
+    Object.keys(nodes).forEach(function (id) { nodes[id].classList.toggle("wm-match", keep !== null && !!keep[id]); });
+  }
+  search.addEventListener("input", reset);
+
+  // Descriptions
+  function describe(n) {
+    return n.description || "";
+  }
+  Object.keys(nodes).forEach(function (id) {
+    var el = nodes[id], n = byID[id];
+    if (!n) { return; }
+    if (n.description) {
+      // The popover replaces the plain text tooltip
+      var title = el.querySelector("title");
+      if (title) { title.remove(); }
+    }
+    el.addEventListener("mouseenter", function (e) {
+      highlight(dependencies(id));
+      if (!n.description) { return; }
+      popover.innerHTML = describe(n);
+      popover.classList.add("wm-popover--open");
+      popover.style.left = Math.min(e.clientX + 12, window.innerWidth - popover.offsetWidth - 8) + "px";
+      popover.style.top = Math.min(e.clientY + 12, window.innerHeight - popover.offsetHeight - 8) + "px";
+    });
+    el.addEventListener("mouseleave", function () {
+      popover.classList.remove("wm-popover--open");
+      reset();
+    });
+    el.addEventListener("click", function (e) {
+      if (el.closest("a")) { return; }
+      e.stopPropagation();
+      var heading = document.createElement("h2");
+      heading.textContent = n.label;
+      sidebar.innerHTML = describe(n);
+      sidebar.insertBefore(heading, sidebar.firstChild);
+      sidebar.classList.add("wm-sidebar--open");
+    });
+  });
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Escape") {
+      sidebar.classList.remove("wm-sidebar--open");
+      search.value = "";
+      reset();
+    }
+  });
+})();
+`
+
+// htmlNode - Node data used by the page script.
+type htmlNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	// Description - Markdown rendered as HTML.
+	Description string `json:"description,omitempty"`
+}
+
+// htmlEdge - Dependency between two nodes.
+type htmlEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type htmlData struct {
+	Nodes []htmlNode `json:"nodes"`
+	Edges []htmlEdge `json:"edges"`
+}
+
+// pageData - Returns the nodes, with their descriptions as HTML, and the dependencies of the map.
+func pageData(m *hcl.Map) htmlData {
+	data := htmlData{Nodes: []htmlNode{}, Edges: []htmlEdge{}}
+	for _, n := range m.Nodes {
+		data.Nodes = append(data.Nodes, htmlNode{
+			ID:          n.ID,
+			Label:       strings.Replace(n.Label, "\n", " ", -1),
+			Description: markdown(n.Description),
+		})
+	}
+	for _, c := range m.Connectors {
+		if c.IsDependency() {
+			data.Edges = append(data.Edges, htmlEdge{From: c.From, To: c.To})
+		}
+	}
+	return data
+}
+
+// htmlPage - Writes a self-contained HTML page with the map inlined.
+// The page has zoom and pan, search, dependency highlighting on hover and Markdown descriptions in a popover and a sidebar.
+// Submap links and breadcrumbs point to the html pages.
+func htmlPage(w io.Writer, m *hcl.Map, pg page) error {
+	pg.html = true
+	var b bytes.Buffer
+	drawing(&b, m, pg)
+	// Drop the XML prolog, it is not valid inside HTML
+	doc := b.String()
+	if i := strings.Index(doc, "<svg"); i >= 0 {
+		doc = doc[i:]
+	}
+	data, err := json.Marshal(pageData(m))
+	if err != nil {
+		return fmt.Errorf("failed to encode map data: %w", err)
+	}
+	fmt.Fprintln(w, "<!DOCTYPE html>")
+	fmt.Fprintln(w, "<html>\n<head>\n<meta charset=\"utf-8\">")
+	fmt.Fprintf(w, "<title>%s</title>\n", html.EscapeString(mapTitle(pg.file, m)))
+	fmt.Fprintf(w, "<style>%s</style>\n", fmt.Sprintf(htmlStyle, m.Theme.Background, m.Theme.Text, m.Font.Family, m.Theme.Grid))
+	fmt.Fprintln(w, "</head>\n<body>")
+	fmt.Fprintln(w, `<div class="wm-toolbar">`)
+	fmt.Fprintln(w, `<input id="wm-search" type="search" placeholder="Search components">`)
+	fmt.Fprintln(w, `<button id="wm-zoom-in" title="Zoom in">+</button>`)
+	fmt.Fprintln(w, `<button id="wm-zoom-out" title="Zoom out">−</button>`)
+	fmt.Fprintln(w, `<button id="wm-zoom-reset" title="Reset zoom">⟲</button>`)
+	fmt.Fprintln(w, `</div>`)
+	fmt.Fprintln(w, `<div class="wm-main">`)
+	fmt.Fprintf(w, "<div class=\"wm-viewport\">\n%s</div>\n", doc)
+	fmt.Fprintln(w, `<aside id="wm-sidebar" class="wm-sidebar"></aside>`)
+	fmt.Fprintln(w, `</div>`)
+	fmt.Fprintln(w, `<div id="wm-popover" class="wm-popover"></div>`)
+	fmt.Fprintf(w, "<script type=\"application/json\" id=\"wm-data\">%s</script>\n", data)
+	fmt.Fprintf(w, "<script>%s</script>\n", htmlScript)
+	fmt.Fprintln(w, "</body>\n</html>")
+	return nil
+}
@@ -0,0 +1,70 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHTMLPage(t *testing.T) {
+	m, pg := testMap(t, "page", `node a {
+			label       = "A"
+			description = "Breaks </script><script>alert(1)</script> out"
+			visibility  = 1
+			evolution   = "custom"
+			x           = 1
+		}
+		node b {
+			label      = "B"
+			visibility = 2
+			evolution  = "product"
+			x          = 1
+		}
+		connector {
+			from = "a"
+			to   = "b"
+		}`)
+	var b bytes.Buffer
+	err := htmlPage(&b, m, pg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	out := b.String()
+	if strings.Contains(out, "<?xml") {
+		t.Errorf("unexpected XML prolog in page:\n%s", out)
+	}
+	if !strings.Contains(out, "<div class=\"wm-viewport\">\n<svg") {
+		t.Errorf("svg is not inlined in the viewport:\n%s", out)
+	}
+
+	// The data block must end at its own closing tag
+	start := strings.Index(out, `<script type="application/json" id="wm-data">`)
+	if start < 0 {
+		t.Fatalf("missing data block:\n%s", out)
+	}
+	block := out[start+len(`<script type="application/json" id="wm-data">`):]
+	end := strings.Index(block, "</script>")
+	if end < 0 {
+		t.Fatalf("unterminated data block:\n%s", out)
+	}
+	var data htmlData
+	err = json.Unmarshal([]byte(block[:end]), &data)
+	if err != nil {
+		t.Fatalf("data block is not valid JSON: %s\n%s", err, block[:end])
+	}
+	if len(data.Nodes) != 2 || !strings.Contains(data.Nodes[0].Description, "&lt;/script&gt;") {
+		t.Errorf("unexpected nodes: %#v", data.Nodes)
+	}
+	if len(data.Edges) != 1 || data.Edges[0] != (htmlEdge{From: "a", To: "b"}) {
+		t.Errorf("unexpected edges: %#v", data.Edges)
+	}
+}
@@ -0,0 +1,67 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Inertia bar height
+const inertiaHeight = 30
+
+// inertiaBar - Returns the bar of the node inertia, on the evolution side of the node.
+func inertiaBar(n *hcl.Node) box {
+	x := n.X + glyphRadius(n.Type) + 8
+	return box{x0: x, y0: n.Y - inertiaHeight/2, x1: x + 4*n.Inertia.Strength, y1: n.Y + inertiaHeight/2}
+}
+
+// placeInertia - Returns the area covered by the inertia bars and their labels.
+func placeInertia(m *hcl.Map) []box {
+	boxes := []box{}
+	for _, n := range m.Nodes {
+		if n.Inertia == nil {
+			continue
+		}
+		b := inertiaBar(n)
+		boxes = append(boxes, b)
+		if n.Inertia.Label != "" {
+			boxes = append(boxes, inertiaLabelBox(n, m.Font.Node))
+		}
+	}
+	return boxes
+}
+
+// inertiaLabel - Returns the first baseline of the inertia label, centred below the bar.
+func inertiaLabel(n *hcl.Node, font *hcl.Font) (int, int, []string) {
+	b := inertiaBar(n)
+	return (b.x0 + b.x1) / 2, b.y1 + font.Size + 2, strings.Split(n.Inertia.Label, "\n")
+}
+
+func inertiaLabelBox(n *hcl.Node, font *hcl.Font) box {
+	x, y, lines := inertiaLabel(n, font)
+	return textBox(x, y, lines, font, "middle")
+}
+
+// drawInertia - Draws the inertia bar next to the node.
+func drawInertia(n *hcl.Node, font *hcl.Font, theme *hcl.Theme) {
+	b := inertiaBar(n)
+	canvas.Group(styleAttrs("wm-inertia", halo(theme.Halo), "")...)
+	if n.Inertia.Label != "" {
+		canvas.Title(n.Inertia.Label)
+	}
+	canvas.Rect(b.x0, b.y0, b.x1-b.x0, b.y1-b.y0, styleAttrs("wm-inertia__bar", fmt.Sprintf("fill:%s;opacity:0.6", theme.ConnectorColor), "")...)
+	if n.Inertia.Label != "" {
+		x, y, lines := inertiaLabel(n, font)
+		textlines(canvas, x, y, lines, font, theme.Text, "middle", "wm-inertia__label")
+	}
+	canvas.Gend()
+}
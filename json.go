@@ -0,0 +1,101 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// jsonSchemaVersion - Version of the exported JSON document, bumped on incompatible changes.
+// The schema is published in schema/map.v<version>.schema.json.
+const jsonSchemaVersion = 1
+
+const jsonSchemaID = "https://github.com/DavidGamba/go-wardley/schema/map.v1.schema.json"
+
+// jsonDocument - Resolved map exported with --format json.
+type jsonDocument struct {
+	Schema  string `json:"$schema"`
+	Version int    `json:"version"`
+	// Plot - Position and size of the plot area in the drawing.
+	// Pixel coordinates are relative to its bottom left corner, with y growing downwards.
+	Plot jsonPlot `json:"plot"`
+	Map  jsonMap  `json:"map"`
+}
+
+type jsonPlot struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type jsonMap struct {
+	*hcl.Map
+	Nodes []jsonNode `json:"nodes"`
+}
+
+type jsonNode struct {
+	*hcl.Node
+	Position jsonPosition `json:"position"`
+}
+
+// jsonPosition - Normalised node position.
+// Evolution goes from 0, the start of the first stage, to 1, the end of the last one.
+// Visibility goes from 0 at the bottom of the value chain to 1 at the top.
+type jsonPosition struct {
+	Evolution  float64 `json:"evolution"`
+	Visibility float64 `json:"visibility"`
+}
+
+// unit - Clamps v to the 0 to 1 range the schema allows.
+// Nodes at the edges of the map can be placed slightly past the plot area.
+func unit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// jsonExport - Writes the map with expressions resolved, defaults applied and the positions computed by the renderer.
+func jsonExport(w io.Writer, m *hcl.Map, pg page) error {
+	// The layout is computed while drawing
+	drawing(ioutil.Discard, m, pg)
+	width := mapGrid.XStageLength * len(mapGrid.Stages)
+	frame := newMapFrame(m.Size)
+	doc := jsonDocument{
+		Schema:  jsonSchemaID,
+		Version: jsonSchemaVersion,
+		Plot: jsonPlot{
+			X:      frame.dx,
+			Y:      frame.dy,
+			Width:  width,
+			Height: mapGrid.YLength,
+		},
+		Map: jsonMap{Map: m, Nodes: []jsonNode{}},
+	}
+	for _, n := range m.Nodes {
+		doc.Map.Nodes = append(doc.Map.Nodes, jsonNode{
+			Node: n,
+			Position: jsonPosition{
+				Evolution:  unit(float64(n.X) / float64(width)),
+				Visibility: unit(float64(-n.Y) / float64(mapGrid.YLength)),
+			},
+		})
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	err := e.Encode(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode map: %w", err)
+	}
+	return nil
+}
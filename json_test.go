@@ -0,0 +1,228 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// schemaErrors - Returns where the value doesn't match the JSON Schema subset used by schema/map.v1.schema.json.
+// Object keys not declared in the schema properties are reported too, so the schema documents every exported field.
+func schemaErrors(root, schema map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		schema = root["$defs"].(map[string]interface{})[name].(map[string]interface{})
+	}
+	errs := []string{}
+	if types, ok := schema["type"]; ok {
+		list := []interface{}{types}
+		if l, ok := types.([]interface{}); ok {
+			list = l
+		}
+		match := false
+		for _, typ := range list {
+			match = match || jsonType(v, typ.(string))
+		}
+		if !match {
+			return append(errs, fmt.Sprintf("%s: %v is not of type %v", path, v, types))
+		}
+	}
+	if c, ok := schema["const"]; ok && c != v {
+		errs = append(errs, fmt.Sprintf("%s: %v is not %v", path, v, c))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, v, enum))
+		}
+	}
+	if n, ok := v.(float64); ok {
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			errs = append(errs, fmt.Sprintf("%s: %v is below %v", path, n, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			errs = append(errs, fmt.Sprintf("%s: %v is above %v", path, n, max))
+		}
+	}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, r := range interfaceList(schema["required"]) {
+			if _, ok := value[r.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required %s", path, r))
+			}
+		}
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, item := range value {
+			s, ok := properties[key].(map[string]interface{})
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s is not in the schema", path, key))
+				continue
+			}
+			errs = append(errs, schemaErrors(root, s, item, path+"."+key)...)
+		}
+	case []interface{}:
+		if s, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				errs = append(errs, schemaErrors(root, s, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+func interfaceList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// jsonType - Reports whether the decoded JSON value has the JSON Schema type.
+func jsonType(v interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func TestJSONExportSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("schema/map.v1.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	err = json.Unmarshal(b, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema["$id"] != jsonSchemaID {
+		t.Errorf("expected schema $id %s, got %v", jsonSchemaID, schema["$id"])
+	}
+
+	example, examplePage := exampleMap(t)
+	features, featuresPage := testMap(t, "features", featureMap)
+	tests := []struct {
+		name string
+		m    *hcl.Map
+		pg   page
+	}{
+		{"example", example, examplePage},
+		{"features", features, featuresPage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err := jsonExport(buf, test.m, test.pg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc interface{}
+			err = json.Unmarshal(buf.Bytes(), &doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range schemaErrors(schema, schema, doc, "$") {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func TestJSONExportEdges(t *testing.T) {
+	m, pg := testMap(t, "edges", `node top {
+			label      = "Top"
+			type       = "anchor"
+			evolution  = "genesis"
+			x          = 0
+		}
+		node bottom {
+			label      = "Bottom"
+			visibility = 9
+			evolution  = "commodity"
+			x          = 1
+		}
+		connector {
+			from = "top"
+			to   = "bottom"
+		}`)
+	buf := new(bytes.Buffer)
+	err := jsonExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Map struct {
+			Nodes []struct {
+				ID       string       `json:"id"`
+				Position jsonPosition `json:"position"`
+			} `json:"nodes"`
+		} `json:"map"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range out.Map.Nodes {
+		p := n.Position
+		if p.Evolution < 0 || p.Evolution > 1 || p.Visibility < 0 || p.Visibility > 1 {
+			t.Errorf("%s: position out of range: %+v", n.ID, p)
+		}
+	}
+	if out.Map.Nodes[0].Position.Evolution != 0 {
+		t.Errorf("expected top at evolution 0, got %+v", out.Map.Nodes[0].Position)
+	}
+}
+
+func TestUnit(t *testing.T) {
+	tests := []struct {
+		v, expected float64
+	}{
+		{-0.1, 0},
+		{0, 0},
+		{0.5, 0.5},
+		{1, 1},
+		{1.02, 1},
+	}
+	for _, test := range tests {
+		if got := unit(test.v); got != test.expected {
+			t.Errorf("unit(%v): expected %v, got %v", test.v, test.expected, got)
+		}
+	}
+}
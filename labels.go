@@ -0,0 +1,295 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Node circle radius
+const nodeRadius = 5
+
+// box - Axis aligned rectangle in map coordinates.
+type box struct {
+	x0, y0, x1, y1 int
+}
+
+// overlap - Returns the overlapping area between both boxes.
+func (b box) overlap(o box) int {
+	w := minInt(b.x1, o.x1) - maxInt(b.x0, o.x0)
+	h := minInt(b.y1, o.y1) - maxInt(b.y0, o.y0)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// intersects - Reports whether the segment a-b crosses the box.
+// Liang–Barsky line clipping.
+func (b box) intersects(ax, ay, bx, by int) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := float64(bx-ax), float64(by-ay)
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return false
+			}
+			if r > t0 {
+				t0 = r
+			}
+		} else {
+			if r < t0 {
+				return false
+			}
+			if r < t1 {
+				t1 = r
+			}
+		}
+		return true
+	}
+	return clip(-dx, float64(ax-b.x0)) &&
+		clip(dx, float64(b.x1-ax)) &&
+		clip(-dy, float64(ay-b.y0)) &&
+		clip(dy, float64(b.y1-ay))
+}
+
+// textBox - Returns an estimate of the area covered by multi line text with its first baseline at y.
+func textBox(x, y int, lines []string, font *hcl.Font, anchor string) box {
+	width := 0
+	for _, l := range lines {
+		if w := utf8.RuneCountInString(l) * font.Size * 6 / 10; w > width {
+			width = w
+		}
+	}
+	height := (len(lines)-1)*(font.Size+3) + font.Size
+	b := box{x0: x, y0: y - font.Size, x1: x + width, y1: y - font.Size + height + font.Size/4}
+	switch anchor {
+	case "end":
+		b.x0, b.x1 = x-width, x
+	case "middle":
+		b.x0, b.x1 = x-width/2, x+width/2
+	}
+	return b
+}
+
+// rotatedTextBox - Returns the unrotated box of text drawn at u, v in a frame rotated by 270 degrees around x, y, like the value chain axis texts.
+// The box is centred where the rotated text ends up, for formats that rotate shapes around their centre.
+func rotatedTextBox(x, y, u, v int, lines []string, font *hcl.Font, anchor string) box {
+	b := textBox(u, v, lines, font, anchor)
+	cx, cy := x+(b.y0+b.y1)/2, y-(b.x0+b.x1)/2
+	w, h := b.x1-b.x0, b.y1-b.y0
+	return box{x0: cx - w/2, y0: cy - h/2, x1: cx - w/2 + w, y1: cy - h/2 + h}
+}
+
+// labelCandidate - Label position relative to the point it labels.
+type labelCandidate struct {
+	name   string
+	anchor string
+	// dx, dy - Offset of the first baseline.
+	dx, dy int
+	// above - The label grows upwards so the first baseline moves up by the extra lines.
+	above bool
+}
+
+// nodeLabelCandidates - The first candidate is the historic label position.
+var nodeLabelCandidates = []labelCandidate{
+	{"right", "start", 8, 10, false},
+	{"top-right", "start", 6, -8, true},
+	{"bottom-right", "start", 6, 18, false},
+	{"left", "end", -8, 10, false},
+	{"top-left", "end", -6, -8, true},
+	{"bottom-left", "end", -6, 18, false},
+	{"top", "middle", 0, -10, true},
+	{"bottom", "middle", 0, 20, false},
+}
+
+// connectorLabelCandidates - Offsets from the connector midpoint.
+var connectorLabelCandidates = []labelCandidate{
+	{"bottom-right", "start", 8, 10, false},
+	{"top-right", "start", 8, -6, true},
+	{"bottom-left", "end", -8, 10, false},
+	{"top-left", "end", -8, -6, true},
+}
+
+// padCandidates - Returns the candidates moved away from the point by pad, for glyphs larger than the node circle.
+func padCandidates(candidates []labelCandidate, pad int) []labelCandidate {
+	if pad == 0 {
+		return candidates
+	}
+	sign := func(v int) int {
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		}
+		return 0
+	}
+	padded := make([]labelCandidate, len(candidates))
+	for i, c := range candidates {
+		c.dx += sign(c.dx) * pad
+		c.dy += sign(c.dy) * pad
+		padded[i] = c
+	}
+	return padded
+}
+
+func (c labelCandidate) position(x, y int, lines []string, font *hcl.Font) (int, int) {
+	y += c.dy
+	if c.above {
+		y -= (len(lines) - 1) * (font.Size + 3)
+	}
+	return x + c.dx, y
+}
+
+// segment - Connector line used as a label obstacle.
+type segment struct {
+	ax, ay, bx, by int
+}
+
+// labelPlacer - Greedily places labels on the candidate with the lowest overlap cost.
+type labelPlacer struct {
+	bounds   box
+	nodes    []box
+	segments []segment
+	placed   []box
+	// nodeLabels - Node label boxes in node order.
+	nodeLabels []box
+	// obstacles - Other areas to avoid, like the legend.
+	obstacles []box
+}
+
+func (p *labelPlacer) cost(b box, index int) int {
+	cost := index
+	for _, o := range p.placed {
+		cost += o.overlap(b) * 4
+	}
+	for _, o := range p.nodes {
+		cost += o.overlap(b) * 8
+	}
+	for _, o := range p.obstacles {
+		cost += o.overlap(b) * 8
+	}
+	for _, s := range p.segments {
+		if b.intersects(s.ax, s.ay, s.bx, s.by) {
+			cost += 40
+		}
+	}
+	area := (b.x1 - b.x0) * (b.y1 - b.y0)
+	cost += (area - p.bounds.overlap(b)) * 8
+	return cost
+}
+
+// place - Returns the position and anchor of the best candidate.
+// When pinned names a candidate only that one is considered.
+func (p *labelPlacer) place(x, y int, lines []string, font *hcl.Font, candidates []labelCandidate, pinned string) (int, int, string) {
+	best, bestCost := 0, -1
+	var bestBox box
+	for i, c := range candidates {
+		if pinned != "" && pinned != "auto" && c.name != pinned {
+			continue
+		}
+		lx, ly := c.position(x, y, lines, font)
+		b := textBox(lx, ly, lines, font, c.anchor)
+		cost := p.cost(b, i)
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost, bestBox = i, cost, b
+		}
+	}
+	p.placed = append(p.placed, bestBox)
+	lx, ly := candidates[best].position(x, y, lines, font)
+	return lx, ly, candidates[best].anchor
+}
+
+// placeNodeLabels - Sets the label position of every node.
+// Labels avoid the labels already placed, the node circles, the given obstacles and the straight lines between connected nodes.
+func placeNodeLabels(m *hcl.Map, nodes map[string]*hcl.Node, obstacles []box) *labelPlacer {
+	p := &labelPlacer{
+		bounds:    box{x0: -m.Size.Margin, y0: -mapGrid.YLength - m.Size.Margin, x1: mapGrid.XStageLength*len(mapGrid.Stages) + m.Size.Margin, y1: m.Size.Margin},
+		obstacles: obstacles,
+	}
+	for _, n := range m.Nodes {
+		if n.IsAnchor() {
+			_, _, b := anchorLabel(n, m.Font.Node)
+			p.nodes = append(p.nodes, b)
+			continue
+		}
+		r := glyphRadius(n.Type)
+		p.nodes = append(p.nodes, box{n.X - r, n.Y - r, n.X + r, n.Y + r})
+	}
+	for _, c := range m.Connectors {
+		a, b := nodes[c.From], nodes[c.To]
+		if a == nil || b == nil {
+			continue
+		}
+		p.segments = append(p.segments, segment{a.X, a.Y, b.X, b.Y})
+	}
+	for _, n := range m.Nodes {
+		if n.IsAnchor() {
+			var b box
+			n.LabelX, n.LabelY, b = anchorLabel(n, m.Font.Node)
+			n.LabelAnchor = "middle"
+			p.placed = append(p.placed, b)
+			continue
+		}
+		candidates := padCandidates(nodeLabelCandidates, glyphRadius(n.Type)-nodeRadius)
+		n.LabelX, n.LabelY, n.LabelAnchor = p.place(n.X, n.Y, strings.Split(n.Label, "\n"), m.Font.Node, candidates, n.LabelPosition)
+	}
+	p.nodeLabels = append([]box{}, p.placed...)
+	return p
+}
+
+// anchorLabel - Returns the first baseline and the box of an anchor label.
+// Anchors are drawn as text centred on the node position.
+func anchorLabel(n *hcl.Node, font *hcl.Font) (int, int, box) {
+	lines := strings.Split(n.Label, "\n")
+	y := n.Y + font.Size/3 - (len(lines)-1)*(font.Size+3)/2
+	return n.X, y, textBox(n.X, y, lines, font, "middle")
+}
+
+// placeConnectorLabels - Sets the label position of every connector around the midpoint of its route.
+// Labels avoid the labels already placed, the node circles and the connector routes.
+func placeConnectorLabels(m *hcl.Map, nodes map[string]*hcl.Node, p *labelPlacer) {
+	p.segments = nil
+	for _, c := range m.Connectors {
+		points := polyline(c)
+		for i := 1; i < len(points); i++ {
+			p.segments = append(p.segments, segment{points[i-1].X, points[i-1].Y, points[i].X, points[i].Y})
+		}
+	}
+	for _, c := range m.Connectors {
+		a, b := nodes[c.From], nodes[c.To]
+		if a == nil || b == nil || c.Label == "" {
+			continue
+		}
+		mid, _, _ := midpoint(c)
+		c.LabelX, c.LabelY, c.LabelAnchor = p.place(mid.X, mid.Y, strings.Split(c.Label, "\n"), m.Font.Connector, connectorLabelCandidates, "")
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+func TestBoxOverlap(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     box
+		expected int
+	}{
+		{"apart", box{0, 0, 10, 10}, box{20, 20, 30, 30}, 0},
+		{"touching", box{0, 0, 10, 10}, box{10, 0, 20, 10}, 0},
+		{"partial", box{0, 0, 10, 10}, box{5, 5, 15, 15}, 25},
+		{"inside", box{0, 0, 10, 10}, box{2, 2, 4, 4}, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.a.overlap(test.b); got != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestBoxIntersects(t *testing.T) {
+	b := box{0, 0, 10, 10}
+	tests := []struct {
+		name           string
+		ax, ay, bx, by int
+		expected       bool
+	}{
+		{"across", -5, 5, 15, 5, true},
+		{"diagonal", -5, -5, 15, 15, true},
+		{"inside", 2, 2, 8, 8, true},
+		{"above", -5, -5, 15, -5, false},
+		{"short of the box", -10, 5, -1, 5, false},
+		{"past the corner", -5, 5, 5, -15, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := b.intersects(test.ax, test.ay, test.bx, test.by); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLabelPlace(t *testing.T) {
+	font := &hcl.Font{Size: 12}
+	bounds := box{0, 0, 200, 200}
+	tests := []struct {
+		name     string
+		placer   labelPlacer
+		pinned   string
+		x, y     int
+		anchor   string
+		position string
+	}{
+		{"free", labelPlacer{bounds: bounds}, "", 108, 110, "start", "right"},
+		{"node on the right", labelPlacer{bounds: bounds, nodes: []box{{110, 100, 120, 110}}}, "", 106, 92, "start", "top-right"},
+		{"label on the right", labelPlacer{bounds: bounds, placed: []box{{110, 100, 150, 110}}}, "", 106, 92, "start", "top-right"},
+		{"line on the right", labelPlacer{bounds: bounds, segments: []segment{{105, 105, 160, 105}}}, "", 106, 92, "start", "top-right"},
+		{"map edge on the right", labelPlacer{bounds: box{0, 0, 105, 200}}, "", 92, 110, "end", "left"},
+		{"pinned", labelPlacer{bounds: bounds}, "left", 92, 110, "end", "left"},
+		{"pinned over a node", labelPlacer{bounds: bounds, nodes: []box{{80, 100, 90, 110}}}, "left", 92, 110, "end", "left"},
+		{"auto", labelPlacer{bounds: bounds}, "auto", 108, 110, "start", "right"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, y, anchor := test.placer.place(100, 100, []string{"label"}, font, nodeLabelCandidates, test.pinned)
+			if x != test.x || y != test.y || anchor != test.anchor {
+				t.Errorf("expected %s label at %d,%d %s, got %d,%d %s", test.position, test.x, test.y, test.anchor, x, y, anchor)
+			}
+			if len(test.placer.placed) == 0 {
+				t.Errorf("expected the label to be kept as an obstacle")
+			}
+		})
+	}
+}
+
+func TestPlaceLabels(t *testing.T) {
+	m, pg := testMap(t, "labels", `node a {
+			label      = "A long label over its neighbours"
+			visibility = 1
+			evolution  = "custom"
+			x          = 1
+		}
+		node b {
+			label      = "B"
+			visibility = 1
+			evolution  = "custom"
+			x          = 2
+		}
+		node c {
+			label      = "C"
+			visibility = 2
+			evolution  = "custom"
+			x          = 2
+		}
+		connector {
+			from  = "a"
+			to    = "c"
+			label = "uses"
+		}`)
+	drawing(new(bytes.Buffer), m, pg)
+
+	labels := []box{}
+	for _, n := range m.Nodes {
+		labels = append(labels, textBox(n.LabelX, n.LabelY, strings.Split(n.Label, "\n"), m.Font.Node, n.LabelAnchor))
+	}
+	for _, c := range m.Connectors {
+		labels = append(labels, textBox(c.LabelX, c.LabelY, strings.Split(c.Label, "\n"), m.Font.Connector, c.LabelAnchor))
+	}
+	for i, l := range labels {
+		for _, n := range m.Nodes {
+			circle := box{n.X - nodeRadius, n.Y - nodeRadius, n.X + nodeRadius, n.Y + nodeRadius}
+			if l.overlap(circle) > 0 {
+				t.Errorf("label %d overlaps node %s", i, n.ID)
+			}
+		}
+		for j, o := range labels[i+1:] {
+			if l.overlap(o) > 0 {
+				t.Errorf("label %d overlaps label %d", i, i+1+j)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Method halo radius
+const methodRadius = 15
+
+// methodStyles - Conventional fill and stroke of the sourcing method halos.
+var methodStyles = map[string]string{
+	"build":     "fill:#d6d6d6;stroke:#000000",
+	"buy":       "fill:#aaa5a9;stroke:#d6d6d6",
+	"outsource": "fill:#444444;stroke:#444444",
+}
+
+// drawMethod - Draws the sourcing method halo around the node position.
+func drawMethod(x, y int, method string) {
+	canvas.Circle(x, y, methodRadius, styleAttrs("wm-method wm-method--"+classID(method), methodStyles[method], "")...)
+}
+
+// legendEntry - Element drawn in the legend.
+type legendEntry struct {
+	label string
+	draw  func(x, y int)
+}
+
+// legendEntries - Returns the entries for the element kinds used in the map.
+func legendEntries(m *hcl.Map) []legendEntry {
+	entries := []legendEntry{}
+	for _, nodeType := range []string{"market", "ecosystem"} {
+		for _, n := range m.Nodes {
+			if n.Type == nodeType {
+				nodeType := nodeType
+				entries = append(entries, legendEntry{nodeType, func(x, y int) {
+					drawGlyph(x, y, nodeType, m.Theme.NodeFill, m.Theme.NodeColor, "")
+				}})
+				break
+			}
+		}
+	}
+	for _, method := range hcl.NodeMethods {
+		for _, n := range m.Nodes {
+			if n.Method == method {
+				method := method
+				entries = append(entries, legendEntry{method, func(x, y int) { drawMethod(x, y, method) }})
+				break
+			}
+		}
+	}
+	for _, direction := range hcl.AcceleratorDirections {
+		for _, a := range m.Accelerators {
+			if a.Direction == direction {
+				direction := direction
+				label := "accelerator"
+				if direction == "backward" {
+					label = "de-accelerator"
+				}
+				entries = append(entries, legendEntry{label, func(x, y int) {
+					x0, x1 := x-methodRadius, x+methodRadius-acceleratorHead
+					if direction == "backward" {
+						x0, x1 = x+methodRadius, x-methodRadius+acceleratorHead+8
+					}
+					drawAccelerator(x0, x1, y, direction, m.Theme)
+				}})
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// Legend row height
+const legendRow = 2*methodRadius + 6
+
+// legendBox - Returns the area covered by the legend in the top right corner of the map.
+func legendBox(entries []legendEntry, font *hcl.Font) box {
+	if len(entries) == 0 {
+		return box{}
+	}
+	width := 0
+	for _, e := range entries {
+		b := textBox(0, 0, []string{e.label}, font, "start")
+		if w := b.x1 - b.x0; w > width {
+			width = w
+		}
+	}
+	width += 2*methodRadius + 24
+	x1 := mapGrid.XStageLength*len(mapGrid.Stages) - 10
+	y0 := -mapGrid.YLength + 10
+	return box{x0: x1 - width, y0: y0, x1: x1, y1: y0 + len(entries)*legendRow + 8}
+}
+
+// legend - Draws the legend box.
+func legend(entries []legendEntry, b box, font *hcl.Font, theme *hcl.Theme) {
+	if len(entries) == 0 {
+		return
+	}
+	canvas.Group(styleAttrs("wm-legend", "", "")...)
+	canvas.Rect(b.x0, b.y0, b.x1-b.x0, b.y1-b.y0, styleAttrs("wm-legend__box", fmt.Sprintf("fill:%s;stroke:%s", theme.Background, theme.Grid), "")...)
+	for i, e := range entries {
+		x := b.x0 + 8 + methodRadius
+		y := b.y0 + 4 + i*legendRow + legendRow/2
+		e.draw(x, y)
+		textlines(canvas, x+methodRadius+8, y+font.Size/3, []string{e.label}, font, theme.Text, "start", "wm-legend__label")
+	}
+	canvas.Gend()
+}
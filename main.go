@@ -9,13 +9,18 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/DavidGamba/go-getoptions"
@@ -34,14 +39,18 @@ var logger = log.New(ioutil.Discard, "", log.LstdFlags)
 // Show guides in drawing
 var showGuides bool
 
+// Input file format, by default selected from each file extension
+var inputFormat string
+
 // Keeps count of connect path IDs
 var connectID int
 
 var canvas *svg.SVG
 
 func main() {
-	var inputFile, outputFile string
+	var inputFile, outputFile, theme, format string
 	var port int
+	var f focus
 
 	opt := getoptions.New()
 	opt.Bool("help", false, opt.Alias("?"))
@@ -50,8 +59,16 @@ func main() {
 	opt.Bool("version", false, opt.Alias("V"), opt.Description("Print version information"))
 	opt.Bool("watch", false, opt.Description("Watch file for changes"))
 	opt.BoolVar(&showGuides, "guides", false, opt.Description("Show margins, limits and other guides in drawing"))
-	opt.StringVar(&inputFile, "file", "", opt.Description("Map input file"), opt.Required(""), opt.ArgName("filename"))
-	opt.StringVar(&outputFile, "output", "", opt.Description("Map svg output file, by default replaces input file extension to .svg"), opt.ArgName("filename"))
+	opt.BoolVar(&useClasses, "classes", false, opt.Description("Use CSS classes and a single <style> block instead of inline styles"))
+	opt.StringVar(&cssFile, "css", "", opt.Description("Stylesheet file to inject into the map, or URL to import.\nImplies --classes"), opt.ArgName("file|url"))
+	opt.StringVar(&theme, "theme", "", opt.Description(fmt.Sprintf("Map theme, overrides the theme selected in the map file.\nBuilt-in themes: %s", strings.Join(hcl.BuiltinThemes(), ", "))), opt.ArgName("name"))
+	opt.StringVar(&inputFile, "file", "", opt.Alias("f"), opt.Description("Map input file"), opt.Required(""), opt.ArgName("filename"))
+	opt.StringVar(&inputFormat, "input-format", "", opt.Description(fmt.Sprintf("Input format of the map files, one of: %s.\nBy default selected from the file extension, .json, .yaml or .yml, and hcl otherwise", strings.Join(hcl.InputFormats, ", "))), opt.ArgName("format"))
+	opt.StringVar(&outputFile, "output", "", opt.Description("Map output file, by default replaces input file extension to .svg or .html"), opt.ArgName("filename"))
+	opt.StringVar(&format, "format", "", opt.Description(fmt.Sprintf("Output format, one of: %s.\nDefaults to the output file extension, or svg", strings.Join(outputFormats, ", "))), opt.ArgName("format"))
+	opt.StringVar(&f.node, "focus", "", opt.Description("Render only the given node and its transitive dependencies"), opt.ArgName("node-id"))
+	opt.IntVar(&f.depth, "depth", 0, opt.Description("Number of dependency steps followed by --focus, 0 follows all of them"), opt.ArgName("n"))
+	opt.StringVar(&f.direction, "direction", "down", opt.Description(fmt.Sprintf("Dependencies followed by --focus, one of: %s.\ndown follows what the node depends on, up follows what depends on it", strings.Join(hcl.FocusDirections, ", "))), opt.ArgName("direction"))
 	_, err := opt.Parse(os.Args[1:])
 	if opt.Called("help") {
 		fmt.Println(opt.Help())
@@ -69,6 +86,27 @@ func main() {
 		logger.SetOutput(os.Stderr)
 		hcl.Logger.SetOutput(os.Stderr)
 	}
+	hcl.ThemeOverride = theme
+	if format == "" {
+		format = "svg"
+		ext := strings.TrimPrefix(filepath.Ext(outputFile), ".")
+		for _, f := range outputFormats {
+			if formatExtension(f) == ext {
+				format = f
+			}
+		}
+	}
+	if inputFormat != "" && !hcl.IsInputFormat(inputFormat) {
+		fmt.Fprintf(os.Stderr, "ERROR: unknown input format '%s', must be one of: %s\n", inputFormat, strings.Join(hcl.InputFormats, ", "))
+		os.Exit(1)
+	}
+	if !isOutputFormat(format) {
+		fmt.Fprintf(os.Stderr, "ERROR: unknown format '%s', must be one of: %s\n", format, strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
+	if cssFile != "" {
+		useClasses = true
+	}
 
 	if opt.Called("serve") {
 		fmt.Printf("Serving content on: http://localhost:%d\n", port)
@@ -99,7 +137,7 @@ func main() {
 					}
 					logger.Printf("watcher event: %s\n", event.String())
 					if event.Name == absFile && event.Op&fsnotify.Write == fsnotify.Write {
-						err := render(absFile, outputFile)
+						err := render(absFile, outputFile, format, f)
 						if err != nil {
 							fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 						}
@@ -121,13 +159,13 @@ func main() {
 			fmt.Fprintf(os.Stderr, "ERROR: watcher error: %s\n", err)
 			os.Exit(1)
 		}
-		err = render(absFile, outputFile)
+		err = render(absFile, outputFile, format, f)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		}
 		<-done
 	} else {
-		err := render(inputFile, outputFile)
+		err := render(inputFile, outputFile, format, f)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			os.Exit(1)
@@ -135,24 +173,61 @@ func main() {
 	}
 }
 
-func render(inputFile, outputFile string) error {
-	m, err := parseInputFile(inputFile)
-	if err != nil {
-		return err
+// outputFormats - Supported output formats.
+var outputFormats = []string{"svg", "html", "json", "dot", "mermaid", "drawio", "excalidraw", "tikz"}
+
+// formatExtension - Returns the output file extension of the format.
+func formatExtension(format string) string {
+	switch format {
+	case "mermaid":
+		return "mmd"
+	case "tikz":
+		return "tex"
 	}
-	if outputFile == "" {
-		outputFile = strings.Replace(inputFile, filepath.Ext(inputFile), ".svg", 1)
-		logger.Printf("output file: %s\n", outputFile)
+	return format
+}
+
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
 	}
-	err = renderFile(m, outputFile)
-	if err != nil {
-		return err
+	return false
+}
+
+// focus - Node, depth and direction of the dependency closure to render, see hcl.Map.Focus.
+type focus struct {
+	node      string
+	depth     int
+	direction string
+}
+
+// apply - Reduces the map to the focus closure, it does nothing without a focus node.
+func (f focus) apply(m *hcl.Map) error {
+	if f.node == "" {
+		return nil
 	}
-	return nil
+	return m.Focus(f.node, f.depth, f.direction)
+}
+
+// render - Renders the map and the tree of submaps it links to.
+// The focus only applies to the input map, submaps are rendered in full.
+func render(inputFile, outputFile, format string, f focus) error {
+	return walkMaps(inputFile, outputFile, nil, map[string]bool{}, func(m *hcl.Map, pg page) error {
+		logger.Printf("output file: %s\n", pg.output)
+		if len(pg.trail) == 0 {
+			err := f.apply(m)
+			if err != nil {
+				return err
+			}
+		}
+		return renderFile(m, pg, format)
+	})
 }
 
 func parseInputFile(name string) (*hcl.Map, error) {
-	parser, f, err := hcl.ParseHCLFile(os.Stderr, name)
+	parser, f, err := hcl.ParseFile(os.Stderr, name, inputFormat)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read '%s': %w", name, err)
 	}
@@ -163,13 +238,39 @@ func parseInputFile(name string) (*hcl.Map, error) {
 	return m, nil
 }
 
-func renderFile(m *hcl.Map, outputFile string) error {
+// renderFile - Writes the map in the given format.
+// Derived output files, svg by default, get the format file extension. Named output files are written as given.
+func renderFile(m *hcl.Map, pg page, format string) error {
+	outputFile := pg.output
+	if !pg.named {
+		outputFile = strings.TrimSuffix(outputFile, ".svg") + "." + formatExtension(format)
+	}
 	ofh, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("failed to write to '%s': %w", outputFile, err)
 	}
 	defer ofh.Close()
-	drawing(ofh, m)
+	switch format {
+	case "html":
+		err = htmlPage(ofh, m, pg)
+	case "json":
+		err = jsonExport(ofh, m, pg)
+	case "dot":
+		err = dotExport(ofh, m, pg)
+	case "mermaid":
+		err = mermaidExport(ofh, m, pg)
+	case "drawio":
+		err = drawioExport(ofh, m, pg)
+	case "excalidraw":
+		err = excalidrawExport(ofh, m, pg)
+	case "tikz":
+		err = tikzExport(ofh, m, pg)
+	default:
+		drawing(ofh, m, pg)
+	}
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Updated file: %s\n", outputFile)
 	return nil
 }
@@ -185,53 +286,117 @@ func serveFile(inputFile string, port int) error {
 
 func drawHandler(inputFile string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		m, err := parseInputFile(inputFile)
-		if err != nil {
+		// Maps are served as interactive html pages and as svg files, at their path relative to the input file
+		// The focus, depth and direction query parameters work like the command line options
+		q := req.URL.Query()
+		f := focus{node: q.Get("focus"), direction: q.Get("direction")}
+		if f.direction == "" {
+			f.direction = "down"
+		}
+		if d := q.Get("depth"); d != "" {
+			var err error
+			f.depth, err = strconv.Atoi(d)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid depth '%s'", d), http.StatusBadRequest)
+				return
+			}
+		}
+		want, ext := "", ".html"
+		if req.URL.Path != "/" {
+			ext = path.Ext(req.URL.Path)
+			if ext != ".html" && ext != ".svg" {
+				http.NotFound(w, req)
+				return
+			}
+			want = filepath.Join(filepath.Dir(inputFile), filepath.FromSlash(path.Clean(req.URL.Path)))
+			want, _ = filepath.Abs(strings.TrimSuffix(want, ext) + ".svg")
+		}
+		found := false
+		err := walkMaps(inputFile, "", nil, map[string]bool{}, func(m *hcl.Map, pg page) error {
+			if want != "" && pg.output != want {
+				return nil
+			}
+			found = true
+			err := f.apply(m)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return errStopWalk
+			}
+			if ext == ".svg" {
+				w.Header().Set("Content-Type", "image/svg+xml")
+				drawing(w, m, pg)
+				return errStopWalk
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = htmlPage(w, m, pg)
+			if err != nil {
+				return err
+			}
+			return errStopWalk
+		})
+		if err != nil && err != errStopWalk {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		}
-		w.Header().Set("Content-Type", "image/svg+xml")
-		drawing(w, m)
+		if !found {
+			http.NotFound(w, req)
+		}
 	}
 }
 
-func drawing(w io.Writer, m *hcl.Map) {
+func drawing(w io.Writer, m *hcl.Map, pg page) {
 	canvas = svg.New(w)
 	canvas.Start(m.Size.Width, m.Size.Height)
-	canvas.Gstyle("font-family:sans-serif")
-	grid(canvas, m.Size.Margin, m.Size.Width, m.Size.Height, m.Size.FontSize+2)
-	canvas.Translate(m.Size.Margin*2, m.Size.Height-m.Size.Margin*2)
+	if m.Meta != nil {
+		metadata(canvas, m.Meta)
+	}
+	if useClasses {
+		rules, err := stylesheet(m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		}
+		canvas.Style("text/css", rules...)
+		if r := importRule(); r != "" {
+			canvas.Style("text/css", r)
+		}
+	}
+	canvas.Group(styleAttrs("wm-map", fmt.Sprintf("font-family:%s;font-weight:%s", m.Font.Family, m.Font.Weight), "")...)
+	grid(canvas, m.Axes, m.Font, m.Theme, m.Size.Margin, m.Size.Width, m.Size.Height)
+	if m.Meta != nil {
+		header(canvas, m.Meta, m.Font, m.Theme, m.Size.Margin, m.Size.Width)
+	}
+	breadcrumb(m, pg)
+	frame := newMapFrame(m.Size)
+	canvas.Translate(frame.dx, frame.dy)
 	canvas.Marker("connector-arrow", 17, 3, 12, 10, `orient="auto"`)
-	canvas.Path("M0,0 L0,6 L12,3 z")
+	canvas.Path("M0,0 L0,6 L12,3 z", styleAttrs("wm-marker", "fill:"+m.Theme.ConnectorColor, "")...)
 	canvas.MarkerEnd()
 	canvas.Marker("connector-inertia", 0, 10, 20, 40, `orient="auto"`)
-	canvas.Path("M-5,20 L-5,-20 L5,-20 L5,20")
+	canvas.Path("M-5,20 L-5,-20 L5,-20 L5,20", styleAttrs("wm-marker", "fill:"+m.Theme.ConnectorColor, "")...)
 	canvas.MarkerEnd()
+	if len(m.Accelerators) > 0 {
+		acceleratorMarkers(m.Theme)
+	}
 
 	nodes := m.Nodes
 	connectors := m.Connectors
 
-	maxGenesis, maxCustom, maxProduct, maxCommodity := 0, 0, 0, 0
-	maxY := 0
+	maxX, maxY := mapScale(m)
+	nodesByID := map[string]*hcl.Node{}
 	for _, n := range nodes {
-		if n.Evolution == "genesis" && n.EvolutionX > maxGenesis {
-			maxGenesis = n.EvolutionX
-		}
-		if n.Evolution == "custom" && n.EvolutionX > maxCustom {
-			maxCustom = n.EvolutionX
-		}
-		if n.Evolution == "product" && n.EvolutionX > maxProduct {
-			maxProduct = n.EvolutionX
-		}
-		if n.Evolution == "commodity" && n.EvolutionX > maxCommodity {
-			maxCommodity = n.EvolutionX
-		}
-		if n.Visibility > maxY {
-			maxY = n.Visibility
-		}
+		NodeXY(n, maxX, maxY)
+		nodesByID[n.ID] = n
 	}
-	for _, n := range nodes {
-		NodeXY(n, maxGenesis, maxCustom, maxProduct, maxCommodity, maxY)
+	entries := legendEntries(m)
+	legendArea := legendBox(entries, m.Font.Node)
+	obstacles := placeAccelerators(m, nodesByID, maxX, maxY)
+	obstacles = append(obstacles, placeInertia(m)...)
+	if len(entries) > 0 {
+		obstacles = append(obstacles, legendArea)
 	}
+	drawRegions(m)
+	p := placeNodeLabels(m, nodesByID, obstacles)
+	routeConnectors(m, nodesByID, p)
+	placeConnectorLabels(m, nodesByID, p)
 	for _, c := range connectors {
 		var a, b *hcl.Node
 		for _, n := range nodes {
@@ -250,11 +415,23 @@ func drawing(w io.Writer, m *hcl.Map) {
 			fmt.Fprintf(os.Stderr, "ERROR: couldn't find node '%s'\n", c.To)
 			continue
 		}
-		connect(c, a, b, m.Size.FontSize)
+		connect(c, a, b, m.Font.Connector, m.Theme)
 	}
 	for _, n := range nodes {
-		DrawNode(n, m.Size.FontSize)
+		if n.Inertia != nil {
+			drawInertia(n, m.Font.Node, m.Theme)
+		}
 	}
+	links := submapLinks(m, pg)
+	for _, n := range nodes {
+		href, ok := links[n.ID]
+		if !ok {
+			href = n.URL
+		}
+		DrawNode(n, m.Font.Node, m.Theme, href)
+	}
+	drawAccelerators(m)
+	legend(entries, legendArea, m.Font.Node, m.Theme)
 	canvas.Gend()
 	canvas.Gend()
 	canvas.End()
@@ -264,87 +441,185 @@ var mapGrid Grid
 
 // Grid -
 type Grid struct {
-	XQuarterLength int
-	YLength        int
-	Genesis        int
-	Custom         int
-	Product        int
-	Commodity      int
-	Visible        int
+	XStageLength int
+	YLength      int
+	// Stages - X start of each evolution stage.
+	Stages  []int
+	Visible int
 }
 
-func NodeXY(n *hcl.Node, maxGenesis, maxCustom, maxProduct, maxCommodity, maxY int) {
-	switch n.Evolution {
-	case "genesis":
-		n.X = mapGrid.Genesis + mapGrid.XQuarterLength/(maxGenesis+1)*n.EvolutionX
-	case "custom":
-		n.X = mapGrid.Custom + mapGrid.XQuarterLength/(maxCustom+1)*n.EvolutionX
-	case "product":
-		n.X = mapGrid.Product + mapGrid.XQuarterLength/(maxProduct+1)*n.EvolutionX
-	case "commodity":
-		n.X = mapGrid.Commodity + mapGrid.XQuarterLength/(maxCommodity+1)*n.EvolutionX
-	}
+// NodeXY - Calculates the node position relative to the max x of its evolution stage and the max visibility.
+func NodeXY(n *hcl.Node, maxX []int, maxY int) {
+	n.X = mapGrid.Stages[n.Stage] + mapGrid.XStageLength/(maxX[n.Stage]+1)*n.EvolutionX
 
 	n.Y = -mapGrid.YLength / (maxY + 1) * (maxY + 1 - n.Visibility)
 }
 
-// DrawNode -
-func DrawNode(n *hcl.Node, fontSize int) {
-	canvas.Gstyle("text-shadow: 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white")
+// DrawNode - Draws the node, wrapped in a link when href is set.
+func DrawNode(n *hcl.Node, font *hcl.Font, theme *hcl.Theme, href string) {
+	if href != "" {
+		link(canvas, href, n.Label)
+		defer canvas.LinkEnd()
+	}
+	class := "wm-node wm-node--" + classID(n.Evolution)
+	if n.Type != "component" {
+		class += " wm-node--" + n.Type
+	}
+	attrs := []string{fmt.Sprintf(`data-node="%s"`, html.EscapeString(n.ID))}
+	// The sourcing method is on the group so pages and stylesheets can filter nodes by it
+	if n.Method != "" {
+		attrs = append(attrs, fmt.Sprintf(`data-method="%s"`, n.Method))
+	}
+	canvas.Group(append(attrs, styleAttrs(class, halo(theme.Halo), "")...)...)
 	if n.Description != "" {
 		canvas.Title(n.Description)
 	} else {
 		canvas.Title(n.Label)
 	}
-	canvas.Circle(n.X, n.Y, 5, fmt.Sprintf("fill:%s;stroke:%s", n.Fill, n.Color))
+	// Anchors are bold text without a circle
+	if n.IsAnchor() {
+		bold := *font
+		bold.Weight = "bold"
+		textlines(canvas, n.LabelX, n.LabelY, strings.Split(n.Label, "\n"), &bold, theme.Text, n.LabelAnchor, "wm-node__label wm-node__label--anchor")
+		canvas.Gend()
+		return
+	}
+	if n.Method != "" {
+		drawMethod(n.X, n.Y, n.Method)
+	}
+	override := []string{}
+	if n.Fill != theme.NodeFill {
+		override = append(override, "fill:"+n.Fill)
+	}
+	if n.Color != theme.NodeColor {
+		override = append(override, "stroke:"+n.Color)
+	}
+	drawGlyph(n.X, n.Y, n.Type, n.Fill, n.Color, strings.Join(override, ";"))
+	if n.Submap != "" {
+		submapGlyph(n.X, n.Y, n.Color)
+	}
 	// canvas.Text(n.X+10, n.Y+3, n.Label, fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black;text-shadow: -1px 0 white, 0 1px white, 1px 0 white, 0 -1px white", nodeFontSize))
 	// canvas.Gstyle("text-shadow: -1px 0 white, 0 1px white, 1px 0 white, 0 -1px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white, 0 0 3px white")
-	canvas.Textlines(n.X+8, n.Y+10, strings.Split(n.Label, "\n"), fontSize, fontSize+3, "black", "left")
+	textlines(canvas, n.LabelX, n.LabelY, strings.Split(n.Label, "\n"), font, theme.Text, n.LabelAnchor, "wm-node__label")
 	canvas.Gend()
 
 }
 
-func connect(c *hcl.Connector, a, b *hcl.Node, fontSize int) {
-	connectID++
+// glyphRadius - Returns the radius of the node glyph.
+func glyphRadius(nodeType string) int {
+	switch nodeType {
+	case "market", "ecosystem":
+		return 2 * nodeRadius
+	}
+	return nodeRadius
+}
 
-	// Calculate midpoints
-	x := a.X + (b.X-a.X)/2
-	if a.X > b.X {
-		x = b.X + (a.X-b.X)/2
+// drawGlyph - Draws the node circle, markets are a circle of circles and ecosystems concentric rings.
+func drawGlyph(x, y int, nodeType, fill, color, override string) {
+	r := glyphRadius(nodeType)
+	canvas.Circle(x, y, r, styleAttrs("wm-node__circle", fmt.Sprintf("fill:%s;stroke:%s", fill, color), override)...)
+	inner := ""
+	if strings.Contains(override, "stroke:") {
+		inner = "fill:" + color
+	}
+	switch nodeType {
+	case "market":
+		points := []string{}
+		for _, p := range marketPoints(x, y, r) {
+			points = append(points, fmt.Sprintf("%d,%d", p.X, p.Y))
+			canvas.Circle(p.X, p.Y, 2, styleAttrs("wm-node__glyph", "fill:"+color, inner)...)
+		}
+		canvas.Path("M "+strings.Join(points, " ")+" Z", styleAttrs("wm-node__glyph-line", "fill:none;stroke:"+color, strings.Replace(inner, "fill:", "stroke:", 1))...)
+	case "ecosystem":
+		canvas.Circle(x, y, r*6/10, styleAttrs("wm-node__glyph-line", "fill:none;stroke:"+color, strings.Replace(inner, "fill:", "stroke:", 1))...)
+		canvas.Circle(x, y, r*2/10, styleAttrs("wm-node__glyph", "fill:"+color, inner)...)
 	}
-	y := a.Y + (b.Y-a.Y)/2
-	if a.Y > b.Y {
-		y = b.Y + (a.Y-b.Y)/2
+}
+
+// marketPoints - Returns the centres of the small circles of the market glyph of radius r.
+func marketPoints(x, y, r int) []hcl.Point {
+	points := []hcl.Point{}
+	for _, angle := range []float64{-90, 30, 150} {
+		points = append(points, hcl.Point{
+			X: x + int(math.Round(float64(r)/2*math.Cos(angle*math.Pi/180))),
+			Y: y + int(math.Round(float64(r)/2*math.Sin(angle*math.Pi/180))),
+		})
+	}
+	return points
+}
+
+func connect(c *hcl.Connector, a, b *hcl.Node, font *hcl.Font, theme *hcl.Theme) {
+	connectID++
+	if c.URL != "" {
+		title := c.Label
+		if title == "" {
+			title = a.Label + " → " + b.Label
+		}
+		link(canvas, c.URL, title)
+		defer canvas.LinkEnd()
+	}
+	// IDs can be any string in JSON and YAML maps
+	canvas.Group(fmt.Sprintf(`data-from="%s"`, html.EscapeString(a.ID)), fmt.Sprintf(`data-to="%s"`, html.EscapeString(b.ID)))
+	defer canvas.Gend()
+
+	d := pathData(c)
+	mid, dx, dy := midpoint(c)
+	// Straight change connectors have the midpoint as a vertex for the inertia marker
+	if c.Shape == "straight" && (c.Type == "change" || c.Type == "change-inertia") {
+		d = fmt.Sprintf("M %d,%d %d,%d %d,%d", a.X, a.Y, mid.X, mid.Y, b.X, b.Y)
 	}
 
 	// canvas.Def()
 	// canvas.Path(fmt.Sprintf("M %d,%d %d,%d %d,%d", a.X, a.Y, x, y, b.X, b.Y), fmt.Sprintf(`id="%d"`, connectID))
 	// canvas.DefEnd()
+	class := "wm-connector wm-connector--" + classID(c.Type)
+	override := ""
+	if c.Color != theme.ConnectorColor {
+		override = "stroke:" + c.Color
+	}
+	// Change connectors are filled with the background, curved and orthogonal ones would fill the area they enclose
+	fill := theme.Background
+	if c.Shape != "straight" && (c.Type == "change" || c.Type == "change-inertia") {
+		fill = "none"
+		override = strings.Trim(override+";fill:none", ";")
+	}
 	switch c.Type {
 	case "normal":
-		canvas.Path(fmt.Sprintf("M %d,%d %d,%d", a.X, a.Y, b.X, b.Y),
-			fmt.Sprintf(`id="%s-%s"`, a.ID, b.ID),
-			fmt.Sprintf(`fill:none;stroke:%s;opacity:0.2`, c.Color))
+		canvas.Path(d,
+			append([]string{fmt.Sprintf(`id="%s-%s"`, html.EscapeString(a.ID), html.EscapeString(b.ID))},
+				styleAttrs(class, fmt.Sprintf(`fill:none;stroke:%s;opacity:0.2`, c.Color), override)...)...)
 	case "bold":
-		canvas.Path(fmt.Sprintf("M %d,%d %d,%d", a.X, a.Y, b.X, b.Y), fmt.Sprintf(`fill:none;stroke:%s;opacity:0.8`, c.Color))
+		canvas.Path(d, styleAttrs(class, fmt.Sprintf(`fill:none;stroke:%s;opacity:0.8`, c.Color), override)...)
 	case "change":
-		canvas.Path(fmt.Sprintf("M %d,%d %d,%d %d,%d", a.X, a.Y, x, y, b.X, b.Y),
-			fmt.Sprintf(`fill:white;stroke:%[1]s;opacity:0.6;stroke-dasharray:6,6;marker-end:url(#connector-arrow)`, c.Color))
+		canvas.Path(d,
+			styleAttrs(class, fmt.Sprintf(`fill:%s;stroke:%s;opacity:0.6;stroke-dasharray:6,6;marker-end:url(#connector-arrow)`, fill, c.Color), override)...)
 	case "change-inertia":
-		canvas.Path(fmt.Sprintf("M %d,%d %d,%d %d,%d", a.X, a.Y, x, y, b.X, b.Y),
-			fmt.Sprintf(`fill:white;stroke:%[1]s;opacity:0.6;stroke-dasharray:6,6;marker-mid:url(#connector-inertia);marker-end:url(#connector-arrow)`, c.Color))
+		if c.Shape == "straight" {
+			canvas.Path(d,
+				styleAttrs(class, fmt.Sprintf(`fill:%s;stroke:%s;opacity:0.6;stroke-dasharray:6,6;marker-mid:url(#connector-inertia);marker-end:url(#connector-arrow)`, fill, c.Color), override)...)
+			break
+		}
+		// Other shapes have more vertices than the midpoint, draw the inertia marker on its own path
+		canvas.Path(d,
+			styleAttrs(class, fmt.Sprintf(`fill:%s;stroke:%s;opacity:0.6;stroke-dasharray:6,6;marker-end:url(#connector-arrow)`, fill, c.Color), strings.Trim(override+";marker-mid:none", ";"))...)
+		length := math.Hypot(dx, dy)
+		if length > 0 {
+			dx, dy = dx/length, dy/length
+		}
+		canvas.Path(fmt.Sprintf("M %.2f,%.2f %d,%d %.2f,%.2f", float64(mid.X)-dx, float64(mid.Y)-dy, mid.X, mid.Y, float64(mid.X)+dx, float64(mid.Y)+dy),
+			styleAttrs("wm-connector__inertia", "fill:none;stroke:none;opacity:0.6;marker-mid:url(#connector-inertia)", "")...)
 	}
-	x += 8
-	y += 10
 
 	// if strings.Contains(c.Label, "\n") {
-	canvas.Textlines(x, y, strings.Split(c.Label, "\n"), fontSize, fontSize+3, "black", "left")
+	if c.Label != "" {
+		textlines(canvas, c.LabelX, c.LabelY, strings.Split(c.Label, "\n"), font, theme.Text, c.LabelAnchor, "wm-connector__label")
+	}
 	// } else {
 	// 	s.Textpath(c.Label, fmt.Sprintf("#%d", connectID), `x="10" y="-5"`, fmt.Sprintf("text-align:left;font-size:%dpx;fill:black", nodeFontSize))
 	// }
 }
 
-func grid(s *svg.SVG, margin, width, height, fontSize int) {
+func grid(s *svg.SVG, axes *hcl.Axes, fonts *hcl.Fonts, theme *hcl.Theme, margin, width, height int) {
 	// Grid
 	//   X
 	xLength := width - margin*4
@@ -355,17 +630,18 @@ func grid(s *svg.SVG, margin, width, height, fontSize int) {
 	yZero := height - margin*2
 	yEnd := margin * 2
 
+	stages := len(axes.Stages)
+
 	mapGrid = Grid{
-		XQuarterLength: (width - margin*4) / 4,
-		Genesis:        0,
-		Custom:         (width - margin*4) / 4,
-		Product:        (width - margin*4) * 2 / 4,
-		Commodity:      (width - margin*4) * 3 / 4,
-		YLength:        height - margin*4,
-		Visible:        0,
+		XStageLength: xLength / stages,
+		YLength:      yLength,
+		Visible:      0,
+	}
+	for i := 0; i < stages; i++ {
+		mapGrid.Stages = append(mapGrid.Stages, xLength*i/stages)
 	}
 
-	s.Rect(0, 0, width, height, "fill:white")
+	s.Rect(0, 0, width, height, styleAttrs("wm-background", "fill:"+theme.Background, "")...)
 
 	if showGuides {
 		// Limits Guide
@@ -378,35 +654,134 @@ func grid(s *svg.SVG, margin, width, height, fontSize int) {
 		s.Line(margin, margin, margin, height-margin, "fill:none;stroke:green")
 
 		s.Translate(xZero, yZero)
-		s.Text(xLength-40, -yLength, fmt.Sprintf("%d,%d", xLength, yLength), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fontSize))
-		s.Text(0, 0, fmt.Sprintf("%d,%d", xZero, yZero), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fontSize))
-		s.Text(xLength/4, 0, fmt.Sprintf("%d,%d", xLength/4, 0), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fontSize))
-		s.Text(xLength*2/4, 0, fmt.Sprintf("%d,%d", 2*margin+xLength*2/4, 0), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fontSize))
-		s.Text(xLength*3/4, 0, fmt.Sprintf("%d,%d", 2*margin+xLength*3/4, 0), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fontSize))
+		s.Text(xLength-40, -yLength, fmt.Sprintf("%d,%d", xLength, yLength), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fonts.Axis.Size))
+		s.Text(0, 0, fmt.Sprintf("%d,%d", xZero, yZero), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fonts.Axis.Size))
+		for _, x := range mapGrid.Stages[1:] {
+			s.Text(x, 0, fmt.Sprintf("%d,%d", 2*margin+x, 0), fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:green", fonts.Axis.Size))
+		}
 		s.Gend()
 	}
 
 	// Grid
 	s.Marker("arrow", 0, 3, 12, 10, `orient="auto"`)
-	s.Path("M0,0 L0,6 L12,3 z", "fill:black")
+	s.Path("M0,0 L0,6 L12,3 z", styleAttrs("wm-axis__arrow", "fill:"+theme.Axis, "")...)
 	s.MarkerEnd()
-	s.Line(xZero, yZero, xEnd, yZero, "fill:none;stroke:black;marker-end:url(#arrow)")
-	s.Line(xZero, yZero, xZero, yEnd, "fill:blue;stroke:black;marker-end:url(#arrow)")
+	s.Line(xZero, yZero, xEnd, yZero, styleAttrs("wm-axis", fmt.Sprintf("fill:none;stroke:%s;marker-end:url(#arrow)", theme.Axis), "")...)
+	s.Line(xZero, yZero, xZero, yEnd, styleAttrs("wm-axis", fmt.Sprintf("fill:none;stroke:%s;marker-end:url(#arrow)", theme.Axis), "")...)
 
-	s.Line(2*margin+xLength/4, yZero, 2*margin+xLength/4, yEnd, `fill:none;stroke:gray;stroke-dasharray:1,10`)
-	s.Line(2*margin+xLength*2/4, yZero, 2*margin+xLength*2/4, yEnd, `fill:none;stroke:gray;stroke-dasharray:1,10`)
-	s.Line(2*margin+xLength*3/4, yZero, 2*margin+xLength*3/4, yEnd, `fill:none;stroke:gray;stroke-dasharray:1,10`)
+	for _, x := range mapGrid.Stages[1:] {
+		s.Line(xZero+x, yZero, xZero+x, yEnd, styleAttrs("wm-axis__stage", fmt.Sprintf(`fill:none;stroke:%s;stroke-dasharray:1,10`, theme.Grid), "")...)
+	}
 
 	// Text
-	s.Text(xZero, height-margin, "Genesis", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black", fontSize))
-	s.Text(2*margin+xLength/4, height-margin, "Custom", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black", fontSize))
-	s.Text(2*margin+xLength*2/4, height-margin, "Product (+rental)", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black", fontSize))
-	s.Text(2*margin+xLength*3/4, height-margin, "Commodity (+utility)", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black", fontSize))
-	s.Text(xEnd-100, height-2*margin-5, "Evolution", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black;font-weight:bold;font-family:serif", fontSize+2))
+	label := fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Axis))
+	title := fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.AxisTitle))
+	for i, x := range mapGrid.Stages {
+		s.Text(xZero+x, height-margin, axes.Stages[i], styleAttrs("wm-axis__label", label, "text-anchor:start")...)
+	}
+	s.Text(xEnd, height-2*margin-5, axes.Evolution, styleAttrs("wm-axis__title", title, "text-anchor:end")...)
 
 	s.TranslateRotate(xZero, yZero, 270)
-	s.Text(0, -5, "Invisible", fmt.Sprintf("text-anchor:top;font-size:%dpx;fill:black", fontSize))
-	s.Text(yLength-50, -5, "Visible", fmt.Sprintf("text-anchor:top;font-size:%dpx;fill:black", fontSize))
-	s.Text(yLength-100, fontSize+2+5, "Value Chain", fmt.Sprintf("text-anchor:left;font-size:%dpx;fill:black;font-weight:bold;font-family:serif", fontSize+2))
+	s.Text(0, -5, axes.Invisible, styleAttrs("wm-axis__label", label, "text-anchor:start")...)
+	s.Text(yLength, -5, axes.Visible, styleAttrs("wm-axis__label", label, "text-anchor:end")...)
+	s.Text(yLength, fonts.AxisTitle.Size+5, axes.ValueChain, styleAttrs("wm-axis__title", title, "text-anchor:end")...)
 	s.Gend()
 }
+
+// metadata - Emits the map details as the document <title> and as Dublin Core <metadata>.
+func metadata(s *svg.SVG, meta *hcl.Meta) {
+	if meta.Title != "" {
+		s.Title(meta.Title)
+	}
+	fmt.Fprintln(s.Writer, "<metadata>")
+	fmt.Fprintln(s.Writer, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">`)
+	fmt.Fprintln(s.Writer, "<rdf:Description>")
+	element := func(tag, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(s.Writer, "<%s>", tag)
+		xml.Escape(s.Writer, []byte(value))
+		fmt.Fprintf(s.Writer, "</%s>\n", tag)
+	}
+	element("dc:title", meta.Title)
+	element("dc:description", meta.Subtitle)
+	element("dc:creator", meta.Author)
+	element("dc:date", meta.Date)
+	element("dcterms:hasVersion", meta.Version)
+	for _, tag := range meta.Tags {
+		element("dc:subject", tag)
+	}
+	element("dc:source", meta.Source)
+	fmt.Fprintln(s.Writer, "</rdf:Description>")
+	fmt.Fprintln(s.Writer, "</rdf:RDF>")
+	fmt.Fprintln(s.Writer, "</metadata>")
+}
+
+// header - Draws the title and subtitle on the top left margin and the author, date and version on the top right.
+func header(s *svg.SVG, meta *hcl.Meta, fonts *hcl.Fonts, theme *hcl.Theme, margin, width int) {
+	xZero := margin * 2
+	xEnd := width - margin*2
+	fontSize := fonts.Size
+
+	muted := fmt.Sprintf("font-size:%dpx;fill:%s", fontSize, theme.Muted)
+	s.Text(xZero, margin, meta.Title, styleAttrs("wm-title", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Title)), "text-anchor:start")...)
+	if meta.Subtitle != "" {
+		s.Text(xZero, margin+fontSize+4, meta.Subtitle, styleAttrs("wm-subtitle", muted, "text-anchor:start")...)
+	}
+
+	if details := metaDetails(meta); details != "" {
+		s.Text(xEnd, margin, details, styleAttrs("wm-details", muted, "text-anchor:end")...)
+	}
+	if meta.Source != "" {
+		link(s, meta.Source, meta.Source)
+		s.Text(xEnd, margin+fontSize+4, meta.Source, styleAttrs("wm-details", muted, "text-anchor:end")...)
+		s.LinkEnd()
+	}
+}
+
+// metaDetails - Returns the author, date and version shown on the top right.
+func metaDetails(meta *hcl.Meta) string {
+	details := []string{}
+	for _, d := range []string{meta.Author, meta.Date, meta.Version} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	return strings.Join(details, " · ")
+}
+
+// fontStyle - Returns the CSS font properties for the given font.
+func fontStyle(f *hcl.Font) string {
+	return fmt.Sprintf("font-family:%s;font-size:%dpx;font-weight:%s", f.Family, f.Size, f.Weight)
+}
+
+// textlines - Draws multi line text with the given font.
+func textlines(s *svg.SVG, x, y int, lines []string, font *hcl.Font, fill, anchor, class string) {
+	override := ""
+	if anchor != "" && anchor != "start" {
+		override = "text-anchor:" + anchor
+	}
+	s.Group(styleAttrs(class, fmt.Sprintf("fill:%s;%s", fill, fontStyle(font)), override)...)
+	for _, t := range lines {
+		s.Text(x, y, t)
+		y += font.Size + 3
+	}
+	s.Gend()
+}
+
+// halo - Returns a text-shadow style that outlines text with the given color so it stands out over lines.
+func halo(color string) string {
+	shadows := []string{}
+	for i := 0; i < 9; i++ {
+		shadows = append(shadows, "0 0 3px "+color)
+	}
+	return "text-shadow: " + strings.Join(shadows, ", ")
+}
+
+// link - Opens a link element, the href is escaped as svgo only escapes the title.
+func link(s *svg.SVG, href, title string) {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(href))
+	s.Link(b.String(), title)
+}
@@ -0,0 +1,239 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// featureMap - Map with one of each element kind and option exported by the renderers.
+const featureMap = `meta {
+	title    = "Features & <tests>"
+	subtitle = "All elements"
+	author   = "Author"
+	date     = "2020-03-01"
+	version  = "1.2"
+	source   = "https://example.com/?a=1&b=2"
+}
+node user {
+	label = "User"
+	type  = "anchor"
+	evolution = "custom"
+	x = 1
+}
+node web {
+	label       = "Web_%site"
+	method      = "buy"
+	url         = "https://example.com/web"
+	description = "Web site"
+	visibility  = 2
+	evolution   = "custom"
+	x           = 2
+	inertia {
+		strength = 2
+		label    = "Legacy"
+	}
+}
+node market {
+	label      = "Market"
+	type       = "market"
+	visibility = 3
+	evolution  = "product"
+	x          = 1
+}
+node eco {
+	label      = "Eco\nsystem"
+	type       = "ecosystem"
+	fill       = "#abc"
+	color      = "navy"
+	visibility = 4
+	evolution  = "commodity"
+	x          = 1
+}
+connector {
+	from  = "user"
+	to    = "web"
+	label = "uses"
+	type  = "bold"
+}
+connector {
+	from = "web"
+	to   = "market"
+}
+connector {
+	from  = "market"
+	to    = "eco"
+	type  = "change-inertia"
+	shape = "curved"
+	label = "evolves"
+}
+connector {
+	from = "web"
+	to   = "eco"
+	type = "change"
+}
+region pioneers {
+	label      = "Pioneers"
+	evolution  = ["genesis", "custom"]
+}
+region team {
+	label = "Team"
+	nodes = ["web", "market"]
+}
+accelerator {
+	label = "Open source"
+	node  = "market"
+}
+accelerator {
+	label      = "Patents"
+	evolution  = "custom"
+	x          = 1
+	visibility = 3
+	direction  = "backward"
+}
+`
+
+// unknownNodeMap - Map with a connector to a node that doesn't exist before a coloured connector.
+const unknownNodeMap = `node a {
+	label      = "A"
+	visibility = 1
+	evolution  = "custom"
+	x          = 1
+}
+node b {
+	label      = "B"
+	visibility = 2
+	evolution  = "product"
+	x          = 1
+}
+connector {
+	from  = "a"
+	to    = "ghost"
+	label = "lbl"
+	type  = "change-inertia"
+}
+connector {
+	from  = "a"
+	to    = "b"
+	color = "red"
+}
+`
+
+// testMap - Returns the decoded HCL map and its page, as rendered to name.svg.
+func testMap(t *testing.T, name, input string) (*hcl.Map, page) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	parser, f, err := hcl.ParseHCL(buf, []byte(input), name+".hcl")
+	if err != nil {
+		t.Fatalf("%s\n%s\n", err, buf.String())
+	}
+	m, err := hcl.DecodeMap(buf, parser, f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, buf.String())
+	}
+	dir := t.TempDir()
+	return m, page{file: filepath.Join(dir, name+".hcl"), output: filepath.Join(dir, name+".svg")}
+}
+
+// exampleMap - Returns the map in examples/map.hcl and its page.
+func exampleMap(t *testing.T) (*hcl.Map, page) {
+	t.Helper()
+	file, err := filepath.Abs("examples/map.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := parseInputFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return m, page{file: file, output: filepath.Join(t.TempDir(), "map.svg")}
+}
+
+func TestDrawingClasses(t *testing.T) {
+	m, pg := testMap(t, "classes", `node a {
+			label      = "A"
+			visibility = 1
+			evolution  = "custom"
+			x          = 1
+		}
+		node b {
+			label      = "B"
+			fill       = "red"
+			visibility = 2
+			evolution  = "product"
+			x          = 1
+		}
+		connector {
+			from = "a"
+			to   = "b"
+			type = "change"
+		}`)
+	defer func(c bool, f string) { useClasses, cssFile = c, f }(useClasses, cssFile)
+	useClasses = true
+	cssFile = "https://example.com/map.css"
+
+	buf := new(bytes.Buffer)
+	drawing(buf, m, pg)
+	out := buf.String()
+
+	for _, expected := range []string{
+		`class="wm-node wm-node--custom"`,
+		`<circle cx="420" cy="-404" r="5" class="wm-node__circle" />`,
+		`class="wm-connector wm-connector--change"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %s in output:\n%s", expected, out)
+		}
+	}
+	if !strings.Contains(out, `fill:red`) {
+		t.Errorf("expected the node fill to stay inline:\n%s", out)
+	}
+	rules := strings.Index(out, ".wm-map {")
+	imported := strings.Index(out, `@import url("https://example.com/map.css");`)
+	if rules < 0 || imported < rules {
+		t.Errorf("expected the stylesheet import after the map rules:\n%s", out)
+	}
+	if strings.Count(out, "<style") != 2 {
+		t.Errorf("expected the map rules and the import in separate <style> blocks:\n%s", out)
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	m, pg := exampleMap(t)
+	derived := strings.TrimSuffix(pg.output, ".svg") + ".json"
+
+	err := renderFile(m, pg, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(derived); err != nil {
+		t.Errorf("expected the derived output file to get the format extension: %s", err)
+	}
+
+	pg.named = true
+	err = renderFile(m, pg, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(pg.output)
+	if err != nil {
+		t.Fatalf("expected the named output file to be written as given: %s", err)
+	}
+	if !json.Valid(b) {
+		t.Errorf("expected json in %s", pg.output)
+	}
+}
@@ -0,0 +1,136 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet   = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	mdOrdered  = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdAutoLink = regexp.MustCompile(`(^|\s)(https?://[^\s<]+)`)
+	mdStrong   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdEmphasis = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	mdHeld     = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// markdown - Converts a Markdown subset to HTML: paragraphs, headings, lists, emphasis, inline code and links.
+// Raw HTML is escaped.
+func markdown(s string) string {
+	out := []string{}
+	paragraph := []string{}
+	list := ""
+	flush := func() {
+		if len(paragraph) > 0 {
+			out = append(out, "<p>"+inline(strings.Join(paragraph, " "))+"</p>")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out = append(out, "</"+list+">")
+			list = ""
+		}
+	}
+	item := func(tag, text string) {
+		flush()
+		if list != tag {
+			closeList()
+			out = append(out, "<"+tag+">")
+			list = tag
+		}
+		out = append(out, "<li>"+inline(text)+"</li>")
+	}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			flush()
+			closeList()
+		case mdHeading.MatchString(line):
+			flush()
+			closeList()
+			m := mdHeading.FindStringSubmatch(line)
+			out = append(out, fmt.Sprintf("<h%d>%s</h%d>", len(m[1]), inline(m[2]), len(m[1])))
+		case mdBullet.MatchString(line):
+			item("ul", mdBullet.FindStringSubmatch(line)[1])
+		case mdOrdered.MatchString(line):
+			item("ol", mdOrdered.FindStringSubmatch(line)[1])
+		default:
+			closeList()
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	closeList()
+	return strings.Join(out, "\n")
+}
+
+// inline - Converts the inline Markdown of a block, code spans are kept verbatim.
+func inline(s string) string {
+	parts := strings.Split(s, "`")
+	for i, p := range parts {
+		p = html.EscapeString(p)
+		// Unclosed backticks are kept as text
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = "<code>" + p + "</code>"
+			continue
+		}
+		if i%2 == 1 {
+			p = "`" + p
+		}
+		// Link targets are held out of the emphasis conversion so _ and * in URLs are kept
+		held := []string{}
+		hold := func(s string) string {
+			held = append(held, s)
+			return fmt.Sprintf("\x00%d\x00", len(held)-1)
+		}
+		p = mdLink.ReplaceAllStringFunc(p, func(l string) string {
+			m := mdLink.FindStringSubmatch(l)
+			if !safeURL(html.UnescapeString(m[2])) {
+				return m[1]
+			}
+			return hold(fmt.Sprintf(`<a href="%s">`, m[2])) + m[1] + "</a>"
+		})
+		p = mdAutoLink.ReplaceAllStringFunc(p, func(l string) string {
+			m := mdAutoLink.FindStringSubmatch(l)
+			return m[1] + hold(fmt.Sprintf(`<a href="%s">%s</a>`, m[2], m[2]))
+		})
+		p = mdStrong.ReplaceAllString(p, "<strong>$1$2</strong>")
+		p = mdEmphasis.ReplaceAllString(p, "<em>$1$2</em>")
+		p = mdHeld.ReplaceAllStringFunc(p, func(h string) string {
+			i, _ := strconv.Atoi(mdHeld.FindStringSubmatch(h)[1])
+			return held[i]
+		})
+		parts[i] = p
+	}
+	return strings.Join(parts, "")
+}
+
+// safeURL - Reports whether the link target can't run script.
+func safeURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		// Relative link
+		return true
+	}
+	switch u[:i] {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"emphasis", "a *b* __c__ `d_e_`", "<p>a <em>b</em> <strong>c</strong> <code>d_e_</code></p>"},
+		{"link", "[*docs*](https://example.com/a_b_c?x=*y*)", `<p><a href="https://example.com/a_b_c?x=*y*"><em>docs</em></a></p>`},
+		{"autolink", "see https://example.com/__init__.py", `<p>see <a href="https://example.com/__init__.py">https://example.com/__init__.py</a></p>`},
+		{"unsafe link", "[x](javascript:alert)", "<p>x</p>"},
+		{"escaped", "<b>", "<p>&lt;b&gt;</p>"},
+		{"escaped text", `Tom & "Jerry" <script>alert(1)</script>`, "<p>Tom &amp; &#34;Jerry&#34; &lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"escaped code", "`<b>&</b>`", "<p><code>&lt;b&gt;&amp;&lt;/b&gt;</code></p>"},
+		{"escaped link target", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1">x</a>)</p>`},
+		{"escaped link text", "[<img>](https://example.com)", `<p><a href="https://example.com">&lt;img&gt;</a></p>`},
+		{"entity in link target", "[x](&#106;avascript:alert)", `<p><a href="&amp;#106;avascript:alert">x</a></p>`},
+		{"unclosed code", "a `b", "<p>a `b</p>"},
+		{"heading and list", "# Title\n- a\n- b\n1. c", "<h1>Title</h1>\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>"},
+		{"paragraphs", "a\nb\n\nc", "<p>a b</p>\n<p>c</p>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := markdown(test.input)
+			if got != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com/a:b", true},
+		{"mailto:team@example.com", true},
+		{"docs/adr.md", true},
+		{"/runbook#step:1", true},
+		{"?q=a:b", true},
+		{"javascript:alert(1)", false},
+		{" JavaScript:alert(1)", false},
+		{"data:text/html,<script>", false},
+		{"vbscript:msgbox", false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			if got := safeURL(test.url); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// mermaidShapes - Opening and closing brackets of the flowchart node shape for each node type.
+var mermaidShapes = map[string][2]string{
+	"component": {"(", ")"},
+	"anchor":    {"([", "])"},
+	"market":    {"[[", "]]"},
+	"ecosystem": {"(((", ")))"},
+}
+
+// mermaidArrows - Flowchart link for each connector type.
+// Change connectors are dotted, inertia ends in a cross.
+var mermaidArrows = map[string]string{
+	"normal":         "-->",
+	"bold":           "==>",
+	"change":         "-.->",
+	"change-inertia": "-.-x",
+}
+
+var mermaidIDRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// mermaidQuote - Returns s as a quoted flowchart label, newlines become line breaks.
+// Labels are rendered as HTML so markup characters are written as entity codes.
+func mermaidQuote(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "&", "#amp;", "<", "#lt;", ">", "#gt;", "\n", "<br>")
+	return `"` + r.Replace(s) + `"`
+}
+
+// mermaidExport - Writes the nodes and connectors as a Mermaid flowchart.
+// Node IDs are reduced to letters, digits and underscores, with a numeric suffix when that makes them collide.
+func mermaidExport(w io.Writer, m *hcl.Map, pg page) error {
+	if m.Meta != nil && m.Meta.Title != "" {
+		fmt.Fprintf(w, "---\ntitle: %q\n---\n", m.Meta.Title)
+	}
+	fmt.Fprintln(w, "flowchart TB")
+	fmt.Fprintf(w, "  classDef default fill:%s,stroke:%s,color:%s\n", m.Theme.NodeFill, m.Theme.NodeColor, m.Theme.Text)
+	ids := map[string]string{}
+	used := map[string]bool{}
+	for _, n := range m.Nodes {
+		id := mermaidIDRe.ReplaceAllString(n.ID, "_")
+		// Keywords like end break the flowchart
+		if id == "end" || id == "graph" || id == "subgraph" {
+			id = "_" + id
+		}
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", mermaidIDRe.ReplaceAllString(n.ID, "_"), i)
+		}
+		used[id] = true
+		ids[n.ID] = id
+		shape := mermaidShapes[n.Type]
+		fmt.Fprintf(w, "  %s%s%s%s\n", id, shape[0], mermaidQuote(n.Label), shape[1])
+	}
+	for _, n := range m.Nodes {
+		style := []string{}
+		if n.Fill != m.Theme.NodeFill {
+			style = append(style, "fill:"+n.Fill)
+		}
+		if n.Color != m.Theme.NodeColor {
+			style = append(style, "stroke:"+n.Color)
+		}
+		if len(style) > 0 {
+			fmt.Fprintf(w, "  style %s %s\n", ids[n.ID], strings.Join(style, ","))
+		}
+		if n.URL != "" {
+			// Entity codes are not decoded in links
+			fmt.Fprintf(w, "  click %s href \"%s\"\n", ids[n.ID], strings.Replace(n.URL, `"`, "%22", -1))
+		}
+	}
+	connectors := graphConnectors(m)
+	for _, c := range connectors {
+		label := ""
+		if c.Label != "" {
+			label = "|" + mermaidQuote(c.Label) + "|"
+		}
+		fmt.Fprintf(w, "  %s %s%s %s\n", ids[c.From], mermaidArrows[c.Type], label, ids[c.To])
+	}
+	if len(connectors) > 0 {
+		fmt.Fprintf(w, "  linkStyle default stroke:%s\n", m.Theme.ConnectorColor)
+	}
+	for i, c := range connectors {
+		if c.Color != m.Theme.ConnectorColor {
+			fmt.Fprintf(w, "  linkStyle %d stroke:%s\n", i, c.Color)
+		}
+	}
+	return nil
+}
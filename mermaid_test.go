@@ -0,0 +1,51 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMermaidExport(t *testing.T) {
+	m, pg := testMap(t, "unknown", unknownNodeMap)
+	buf := new(bytes.Buffer)
+	err := mermaidExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "lbl") {
+		t.Errorf("expected the connector to an unknown node to be skipped:\n%s", out)
+	}
+	if !strings.Contains(out, "  a --> b\n") {
+		t.Errorf("expected the a --> b link:\n%s", out)
+	}
+	// Link styles are indexed by the links written
+	if !strings.Contains(out, "  linkStyle 0 stroke:red\n") {
+		t.Errorf("expected the a --> b link style at index 0:\n%s", out)
+	}
+}
+
+func TestMermaidQuote(t *testing.T) {
+	tests := []struct {
+		s, expected string
+	}{
+		{"Web", `"Web"`},
+		{"Say \"hi\"", `"Say #quot;hi#quot;"`},
+		{"Eco\nsystem", `"Eco<br>system"`},
+		{"<b>A & B</b>", `"#lt;b#gt;A #amp; B#lt;/b#gt;"`},
+	}
+	for _, test := range tests {
+		if got := mermaidQuote(test.s); got != test.expected {
+			t.Errorf("mermaidQuote(%q): expected %s, got %s", test.s, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Distance between the member nodes and the edge of a region hull
+const regionPadding = 20
+
+// regionArea - Outline of a region in map coordinates.
+type regionArea struct {
+	region *hcl.Region
+	points []hcl.Point
+	// labelX, labelY - First baseline of the label, inside the top left corner.
+	labelX, labelY int
+}
+
+// regionAreas - Returns the outline of the regions, node regions without nodes in the map are left out.
+func regionAreas(m *hcl.Map) []regionArea {
+	_, maxY := mapScale(m)
+	nodes := map[string]*hcl.Node{}
+	for _, n := range m.Nodes {
+		nodes[n.ID] = n
+	}
+	areas := []regionArea{}
+	for _, r := range m.Regions {
+		var points []hcl.Point
+		if len(r.Nodes) > 0 {
+			points = regionHull(r, nodes)
+		} else {
+			points = regionRect(r, maxY)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		b := pointsBox(points)
+		areas = append(areas, regionArea{region: r, points: points, labelX: b.x0 + 6, labelY: b.y0 + m.Font.Node.Size + 4})
+	}
+	return areas
+}
+
+// pointsBox - Returns the bounding box of the points.
+func pointsBox(points []hcl.Point) box {
+	b := box{x0: points[0].X, y0: points[0].Y, x1: points[0].X, y1: points[0].Y}
+	for _, p := range points {
+		b.x0, b.y0 = minInt(b.x0, p.X), minInt(b.y0, p.Y)
+		b.x1, b.y1 = maxInt(b.x1, p.X), maxInt(b.y1, p.Y)
+	}
+	return b
+}
+
+// drawRegions - Draws the region overlays, they go behind connectors and nodes.
+func drawRegions(m *hcl.Map) {
+	for _, a := range regionAreas(m) {
+		r := a.region
+		d := []string{}
+		for _, p := range a.points {
+			d = append(d, fmt.Sprintf("%d,%d", p.X, p.Y))
+		}
+		canvas.Group(styleAttrs("wm-region wm-region--"+classID(r.ID), "", "")...)
+		if r.Label != "" {
+			canvas.Title(r.Label)
+		}
+		override := []string{}
+		if r.Fill != m.Theme.Muted {
+			override = append(override, "fill:"+r.Fill)
+		}
+		if r.Opacity != 0.2 {
+			override = append(override, fmt.Sprintf("opacity:%g", r.Opacity))
+		}
+		canvas.Path("M "+strings.Join(d, " ")+" Z",
+			styleAttrs("wm-region__area", fmt.Sprintf("fill:%s;opacity:%g", r.Fill, r.Opacity), strings.Join(override, ";"))...)
+		if r.Label != "" {
+			textlines(canvas, a.labelX, a.labelY, strings.Split(r.Label, "\n"), m.Font.Node, m.Theme.Text, "start", "wm-region__label")
+		}
+		canvas.Gend()
+	}
+}
+
+// visibilityY - Returns the y of the given visibility, see NodeXY.
+func visibilityY(v, maxY int) int {
+	return -mapGrid.YLength / (maxY + 1) * (maxY + 1 - v)
+}
+
+// evolutionX - Returns the x of the given evolution between 0 and 1.
+func evolutionX(e float64) int {
+	return int(math.Round(e * float64(mapGrid.XStageLength*len(mapGrid.Stages))))
+}
+
+// regionRect - Returns the corners of a rectangle region.
+// The rectangle spans its evolution bounds and extends half a visibility step beyond the top and bottom visibility.
+func regionRect(r *hcl.Region, maxY int) []hcl.Point {
+	x0 := evolutionX(r.Bounds[0])
+	x1 := evolutionX(r.Bounds[1])
+	y0, y1 := -mapGrid.YLength, 0
+	if len(r.Visibility) > 0 {
+		top, bottom := r.Visibility[0], r.Visibility[len(r.Visibility)-1]
+		if top > bottom {
+			top, bottom = bottom, top
+		}
+		step := mapGrid.YLength / (maxY + 1)
+		y0 = maxInt(visibilityY(top, maxY)-step/2, -mapGrid.YLength)
+		y1 = minInt(visibilityY(bottom, maxY)+step/2, 0)
+	}
+	return []hcl.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+}
+
+// regionHull - Returns the convex hull around the member nodes, padded by regionPadding.
+func regionHull(r *hcl.Region, nodes map[string]*hcl.Node) []hcl.Point {
+	points := []hcl.Point{}
+	for _, id := range r.Nodes {
+		n, ok := nodes[id]
+		if !ok {
+			continue
+		}
+		// Octagon around the node
+		radius := float64(regionPadding + glyphRadius(n.Type))
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			points = append(points, hcl.Point{
+				X: n.X + int(math.Round(radius*math.Cos(angle))),
+				Y: n.Y + int(math.Round(radius*math.Sin(angle))),
+			})
+		}
+	}
+	return convexHull(points)
+}
+
+// convexHull - Andrew's monotone chain, returns the hull in clockwise screen order.
+func convexHull(points []hcl.Point) []hcl.Point {
+	if len(points) < 3 {
+		return points
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].X == points[j].X {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+	cross := func(o, a, b hcl.Point) int {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	hull := []hcl.Point{}
+	for _, p := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(points) - 2; i >= 0; i-- {
+		p := points[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
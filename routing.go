@@ -0,0 +1,181 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Distance between the alternative orthogonal routes
+const routeStep = 20
+
+// curveSamples - Number of segments used to approximate a curve when checking for obstacles.
+const curveSamples = 16
+
+// routeConnectors - Sets the route of every connector.
+// Curved and orthogonal connectors pick the route that crosses the fewest node circles, node labels and obstacles.
+func routeConnectors(m *hcl.Map, nodes map[string]*hcl.Node, p *labelPlacer) {
+	// Connectors start below anchors so they don't cross the anchor text
+	endpoint := func(n *hcl.Node) hcl.Point {
+		for i, o := range m.Nodes {
+			if o == n && n.IsAnchor() {
+				return hcl.Point{X: n.X, Y: p.nodes[i].y1}
+			}
+		}
+		return hcl.Point{X: n.X, Y: n.Y}
+	}
+	for _, c := range m.Connectors {
+		a, b := nodes[c.From], nodes[c.To]
+		if a == nil || b == nil {
+			continue
+		}
+		// Obstacles other than the connected nodes and their labels
+		obstacles := append([]box{}, p.obstacles...)
+		for i, n := range m.Nodes {
+			if n == a || n == b {
+				continue
+			}
+			obstacles = append(obstacles, p.nodes[i], p.nodeLabels[i])
+		}
+		c.Route = route(c.Shape, endpoint(a), endpoint(b), obstacles)
+	}
+}
+
+// route - Returns the route with the lowest cost for the given shape.
+func route(shape string, a, b hcl.Point, obstacles []box) []hcl.Point {
+	candidates := [][]hcl.Point{}
+	switch shape {
+	case "curved":
+		dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+		mx, my := float64(a.X+b.X)/2, float64(a.Y+b.Y)/2
+		// Offsets perpendicular to the segment, as a fraction of its length
+		for _, f := range []float64{0.2, -0.2, 0.35, -0.35, 0.5, -0.5} {
+			control := hcl.Point{X: int(math.Round(mx - dy*f)), Y: int(math.Round(my + dx*f))}
+			candidates = append(candidates, []hcl.Point{a, control, b})
+		}
+	case "orthogonal":
+		if a.X == b.X || a.Y == b.Y {
+			return []hcl.Point{a, b}
+		}
+		candidates = append(candidates,
+			[]hcl.Point{a, {X: b.X, Y: a.Y}, b},
+			[]hcl.Point{a, {X: a.X, Y: b.Y}, b},
+		)
+		mx, my := (a.X+b.X)/2, (a.Y+b.Y)/2
+		for _, shift := range []int{0, 1, -1, 2, -2, 3, -3} {
+			x := mx + shift*routeStep
+			candidates = append(candidates, []hcl.Point{a, {X: x, Y: a.Y}, {X: x, Y: b.Y}, b})
+			y := my + shift*routeStep
+			candidates = append(candidates, []hcl.Point{a, {X: a.X, Y: y}, {X: b.X, Y: y}, b})
+		}
+	default:
+		return []hcl.Point{a, b}
+	}
+
+	best, bestCost := 0, -1
+	for i, points := range candidates {
+		polyline := points
+		if shape == "curved" {
+			polyline = flatten(points)
+		}
+		cost := i
+		for j := 1; j < len(polyline); j++ {
+			for _, o := range obstacles {
+				if o.intersects(polyline[j-1].X, polyline[j-1].Y, polyline[j].X, polyline[j].Y) {
+					cost += 100
+				}
+			}
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = i, cost
+		}
+	}
+	return candidates[best]
+}
+
+// quadratic - Returns the point at t of the quadratic Bézier a, control, b.
+func quadratic(a, control, b hcl.Point, t float64) (float64, float64) {
+	u := 1 - t
+	x := u*u*float64(a.X) + 2*u*t*float64(control.X) + t*t*float64(b.X)
+	y := u*u*float64(a.Y) + 2*u*t*float64(control.Y) + t*t*float64(b.Y)
+	return x, y
+}
+
+// flatten - Returns a polyline approximation of a curved route.
+func flatten(route []hcl.Point) []hcl.Point {
+	points := []hcl.Point{}
+	for i := 0; i <= curveSamples; i++ {
+		x, y := quadratic(route[0], route[1], route[2], float64(i)/curveSamples)
+		points = append(points, hcl.Point{X: int(math.Round(x)), Y: int(math.Round(y))})
+	}
+	return points
+}
+
+// polyline - Returns the route as a polyline, curves are approximated.
+func polyline(c *hcl.Connector) []hcl.Point {
+	if c.Shape == "curved" && len(c.Route) == 3 {
+		return flatten(c.Route)
+	}
+	return c.Route
+}
+
+// midpoint - Returns the point halfway along the route and the direction of the route at that point.
+func midpoint(c *hcl.Connector) (hcl.Point, float64, float64) {
+	if c.Shape == "curved" && len(c.Route) == 3 {
+		x, y := quadratic(c.Route[0], c.Route[1], c.Route[2], 0.5)
+		// Derivative at t=0.5 is parallel to b - a
+		return hcl.Point{X: int(math.Round(x)), Y: int(math.Round(y))}, float64(c.Route[2].X - c.Route[0].X), float64(c.Route[2].Y - c.Route[0].Y)
+	}
+	points := c.Route
+	if len(points) == 0 {
+		return hcl.Point{}, 0, 0
+	}
+	if len(points) == 2 {
+		return hcl.Point{X: points[0].X + (points[1].X-points[0].X)/2, Y: points[0].Y + (points[1].Y-points[0].Y)/2}, float64(points[1].X - points[0].X), float64(points[1].Y - points[0].Y)
+	}
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += math.Hypot(float64(points[i].X-points[i-1].X), float64(points[i].Y-points[i-1].Y))
+	}
+	half := total / 2
+	for i := 1; i < len(points); i++ {
+		dx, dy := float64(points[i].X-points[i-1].X), float64(points[i].Y-points[i-1].Y)
+		length := math.Hypot(dx, dy)
+		if length >= half && length > 0 {
+			f := half / length
+			return hcl.Point{X: points[i-1].X + int(math.Round(dx*f)), Y: points[i-1].Y + int(math.Round(dy*f))}, dx, dy
+		}
+		half -= length
+	}
+	return points[0], 0, 0
+}
+
+// connectorInertiaBar - Returns the inertia bar of a change connector, centred on the route midpoint before rotation,
+// and its rotation in radians, the direction of the route at the midpoint, as placed by the connector-inertia marker.
+func connectorInertiaBar(c *hcl.Connector) (box, float64) {
+	mid, dx, dy := midpoint(c)
+	return box{x0: mid.X - 5, y0: mid.Y - 20, x1: mid.X + 5, y1: mid.Y + 20}, math.Atan2(dy, dx)
+}
+
+// pathData - Returns the SVG path data for the route.
+func pathData(c *hcl.Connector) string {
+	if c.Shape == "curved" && len(c.Route) == 3 {
+		a, control, b := c.Route[0], c.Route[1], c.Route[2]
+		return fmt.Sprintf("M %d,%d Q %d,%d %d,%d", a.X, a.Y, control.X, control.Y, b.X, b.Y)
+	}
+	d := []string{}
+	for _, p := range c.Route {
+		d = append(d, fmt.Sprintf("%d,%d", p.X, p.Y))
+	}
+	return "M " + strings.Join(d, " ")
+}
@@ -0,0 +1,84 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+func TestRoute(t *testing.T) {
+	a, b := hcl.Point{X: 0, Y: 0}, hcl.Point{X: 100, Y: 100}
+	tests := []struct {
+		name      string
+		shape     string
+		b         hcl.Point
+		obstacles []box
+		expected  []hcl.Point
+	}{
+		{"straight", "straight", b, []box{{40, 40, 60, 60}}, []hcl.Point{a, b}},
+		{"orthogonal aligned", "orthogonal", hcl.Point{X: 100, Y: 0}, nil, []hcl.Point{a, {X: 100, Y: 0}}},
+		{"orthogonal", "orthogonal", b, nil, []hcl.Point{a, {X: 100, Y: 0}, b}},
+		{"orthogonal around an obstacle", "orthogonal", b, []box{{40, -5, 60, 5}}, []hcl.Point{a, {X: 0, Y: 100}, b}},
+		{"orthogonal with both corners blocked", "orthogonal", b, []box{{40, -5, 60, 5}, {-5, 40, 5, 60}}, []hcl.Point{a, {X: 30, Y: 0}, {X: 30, Y: 100}, b}},
+		{"curved", "curved", b, nil, []hcl.Point{a, {X: 30, Y: 70}, b}},
+		{"curved around an obstacle", "curved", b, []box{{35, 55, 45, 65}}, []hcl.Point{a, {X: 70, Y: 30}, b}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := route(test.shape, a, test.b, test.obstacles)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        *hcl.Connector
+		expected hcl.Point
+		dx, dy   float64
+	}{
+		{"straight", &hcl.Connector{Shape: "straight", Route: []hcl.Point{{X: 0, Y: 0}, {X: 100, Y: 0}}}, hcl.Point{X: 50, Y: 0}, 100, 0},
+		{"orthogonal", &hcl.Connector{Shape: "orthogonal", Route: []hcl.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}}}, hcl.Point{X: 100, Y: 0}, 100, 0},
+		{"orthogonal second leg", &hcl.Connector{Shape: "orthogonal", Route: []hcl.Point{{X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 100}}}, hcl.Point{X: 20, Y: 40}, 0, 100},
+		{"curved", &hcl.Connector{Shape: "curved", Route: []hcl.Point{{X: 0, Y: 0}, {X: 30, Y: 70}, {X: 100, Y: 100}}}, hcl.Point{X: 40, Y: 60}, 100, 100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, dx, dy := midpoint(test.c)
+			if p != test.expected || dx != test.dx || dy != test.dy {
+				t.Errorf("expected %v %v,%v, got %v %v,%v", test.expected, test.dx, test.dy, p, dx, dy)
+			}
+		})
+	}
+}
+
+func TestPathData(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        *hcl.Connector
+		expected string
+	}{
+		{"straight", &hcl.Connector{Shape: "straight", Route: []hcl.Point{{X: 0, Y: 0}, {X: 100, Y: 0}}}, "M 0,0 100,0"},
+		{"orthogonal", &hcl.Connector{Shape: "orthogonal", Route: []hcl.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}}}, "M 0,0 100,0 100,100"},
+		{"curved", &hcl.Connector{Shape: "curved", Route: []hcl.Point{{X: 0, Y: 0}, {X: 30, Y: 70}, {X: 100, Y: 100}}}, "M 0,0 Q 30,70 100,100"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pathData(test.c); got != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// Render semantic classes and a single <style> block instead of inline styles.
+var useClasses bool
+
+// External stylesheet. Local files are injected into the <style> block, URLs are imported after it.
+var cssFile string
+
+// styleAttrs - Returns the class attribute in class mode or the inline style otherwise.
+// The override holds element specific properties the stylesheet doesn't know about so it is always inline.
+func styleAttrs(class, style, override string) []string {
+	if !useClasses {
+		style = strings.Trim(style+";"+override, ";")
+		if style == "" {
+			return nil
+		}
+		return []string{style}
+	}
+	attrs := []string{fmt.Sprintf(`class="%s"`, class)}
+	if override != "" {
+		attrs = append(attrs, override)
+	}
+	return attrs
+}
+
+// styleColors - Returns the stroke and fill colours of the svg style, empty when not set.
+func styleColors(svgStyle string) (string, string) {
+	stroke, fill := "", ""
+	for _, rule := range strings.Split(svgStyle, ";") {
+		kv := strings.SplitN(rule, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "fill":
+			fill = kv[1]
+		case "stroke":
+			stroke = kv[1]
+		}
+	}
+	return stroke, fill
+}
+
+// cssColors - Hex values of the basic css colour keywords.
+var cssColors = map[string]string{
+	"black":   "000000",
+	"silver":  "C0C0C0",
+	"gray":    "808080",
+	"grey":    "808080",
+	"white":   "FFFFFF",
+	"maroon":  "800000",
+	"red":     "FF0000",
+	"purple":  "800080",
+	"fuchsia": "FF00FF",
+	"green":   "008000",
+	"lime":    "00FF00",
+	"olive":   "808000",
+	"yellow":  "FFFF00",
+	"navy":    "000080",
+	"blue":    "0000FF",
+	"teal":    "008080",
+	"aqua":    "00FFFF",
+	"orange":  "FFA500",
+}
+
+var hexColorRe = regexp.MustCompile(`^#([0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+
+// colorHex - Returns the six digit hex value of a hex colour or a basic css colour keyword, empty for other colours.
+func colorHex(color string) string {
+	if hex, ok := cssColors[strings.ToLower(color)]; ok {
+		return hex
+	}
+	if !hexColorRe.MatchString(color) {
+		return ""
+	}
+	h := strings.ToUpper(color[1:])
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	return h
+}
+
+var classIDRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// classID - Returns a string that can be used as part of a class name.
+func classID(s string) string {
+	return strings.Trim(classIDRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// stylesheet - Returns the rules for the map classes followed by the injected stylesheet file.
+// Rules are plain class selectors, modifiers come after the element rules they override.
+// Injected rules come last so they win over the map rules by source order.
+func stylesheet(m *hcl.Map) ([]string, error) {
+	theme := m.Theme
+	fonts := m.Font
+	rules := []string{}
+	rule := func(class, style string) {
+		rules = append(rules, fmt.Sprintf(".%s { %s }", class, style))
+	}
+	rule("wm-map", fmt.Sprintf("font-family:%s;font-weight:%s", fonts.Family, fonts.Weight))
+	rule("wm-background", "fill:"+theme.Background)
+	rule("wm-axis", fmt.Sprintf("fill:none;stroke:%s;marker-end:url(#arrow)", theme.Axis))
+	rule("wm-axis__arrow", "fill:"+theme.Axis)
+	rule("wm-axis__stage", fmt.Sprintf("fill:none;stroke:%s;stroke-dasharray:1,10", theme.Grid))
+	rule("wm-axis__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Axis)))
+	rule("wm-axis__title", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.AxisTitle)))
+	rule("wm-title", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Title)))
+	rule("wm-subtitle", fmt.Sprintf("fill:%s;font-size:%dpx", theme.Muted, fonts.Size))
+	rule("wm-details", fmt.Sprintf("fill:%s;font-size:%dpx", theme.Muted, fonts.Size))
+	rule("wm-marker", "fill:"+theme.ConnectorColor)
+	rule("wm-node", halo(theme.Halo))
+	rule("wm-node__circle", fmt.Sprintf("fill:%s;stroke:%s", theme.NodeFill, theme.NodeColor))
+	rule("wm-node__glyph", "fill:"+theme.NodeColor)
+	rule("wm-node__glyph-line", "fill:none;stroke:"+theme.NodeColor)
+	rule("wm-node__submap", "fill:"+theme.NodeColor)
+	rule("wm-node__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Node)))
+	rule("wm-node__label--anchor", "font-weight:bold")
+	for _, method := range hcl.NodeMethods {
+		rule("wm-method--"+method, methodStyles[method])
+	}
+	rule("wm-legend__box", fmt.Sprintf("fill:%s;stroke:%s", theme.Background, theme.Grid))
+	rule("wm-legend__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Node)))
+	rule("wm-region__area", fmt.Sprintf("fill:%s;opacity:0.2", theme.Muted))
+	rule("wm-region__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Node)))
+	rule("wm-accelerator", halo(theme.Halo))
+	rule("wm-accelerator__arrow", fmt.Sprintf("stroke:%s;stroke-width:10;opacity:0.6", theme.ConnectorColor))
+	rule("wm-accelerator__arrow--forward", "marker-end:url(#accelerator)")
+	rule("wm-accelerator__arrow--backward", "marker-end:url(#deaccelerator)")
+	rule("wm-accelerator__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Node)))
+	rule("wm-inertia", halo(theme.Halo))
+	rule("wm-inertia__bar", fmt.Sprintf("fill:%s;opacity:0.6", theme.ConnectorColor))
+	rule("wm-inertia__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Node)))
+	rule("wm-breadcrumb", fmt.Sprintf("fill:%s;font-size:%dpx", theme.Muted, fonts.Size))
+	rule("wm-connector", fmt.Sprintf("fill:none;stroke:%s", theme.ConnectorColor))
+	rule("wm-connector--normal", "opacity:0.2")
+	rule("wm-connector--bold", "opacity:0.8")
+	rule("wm-connector--change", fmt.Sprintf("fill:%s;opacity:0.6;stroke-dasharray:6,6;marker-end:url(#connector-arrow)", theme.Background))
+	rule("wm-connector--change-inertia", fmt.Sprintf("fill:%s;opacity:0.6;stroke-dasharray:6,6;marker-mid:url(#connector-inertia);marker-end:url(#connector-arrow)", theme.Background))
+	rule("wm-connector__inertia", "fill:none;stroke:none;opacity:0.6;marker-mid:url(#connector-inertia)")
+	rule("wm-connector__label", fmt.Sprintf("fill:%s;%s", theme.Text, fontStyle(fonts.Connector)))
+
+	if cssFile != "" && !isURL(cssFile) {
+		data, err := ioutil.ReadFile(cssFile)
+		if err != nil {
+			return rules, fmt.Errorf("failed to read css file '%s': %w", cssFile, err)
+		}
+		rules = append(rules, string(data))
+	}
+	return rules, nil
+}
+
+// importRule - Returns the rule importing the external stylesheet URL, empty for local files.
+// @import must come first in a stylesheet so it goes in its own <style> block after the map rules.
+func importRule() string {
+	if !isURL(cssFile) {
+		return ""
+	}
+	return fmt.Sprintf(`@import url("%s");`, cssFile)
+}
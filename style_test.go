@@ -0,0 +1,102 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStyleAttrs(t *testing.T) {
+	tests := []struct {
+		name       string
+		useClasses bool
+		style      string
+		override   string
+		expected   []string
+	}{
+		{"inline", false, "fill:white", "", []string{"fill:white"}},
+		{"inline override", false, "fill:white", "fill:red", []string{"fill:white;fill:red"}},
+		{"inline empty", false, "", "", nil},
+		{"class", true, "fill:white", "", []string{`class="wm-node"`}},
+		{"class override", true, "fill:white", `style="fill:red"`, []string{`class="wm-node"`, `style="fill:red"`}},
+	}
+	defer func(c bool) { useClasses = c }(useClasses)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useClasses = test.useClasses
+			attrs := styleAttrs("wm-node", test.style, test.override)
+			if !reflect.DeepEqual(attrs, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, attrs)
+			}
+		})
+	}
+}
+
+func TestStylesheet(t *testing.T) {
+	m, _ := testMap(t, "stylesheet", `theme = "dark"`)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "map.css")
+	err := ioutil.WriteFile(local, []byte(".wm-node__circle { fill:red }"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cssFile string
+		last    string
+		imports string
+		err     bool
+	}{
+		{"no stylesheet", "", ".wm-connector__label { ", "", false},
+		{"local file", local, ".wm-node__circle { fill:red }", "", false},
+		{"url", "https://example.com/map.css", ".wm-connector__label { ", `@import url("https://example.com/map.css");`, false},
+		{"missing file", filepath.Join(dir, "missing.css"), ".wm-connector__label { ", "", true},
+	}
+	defer func(f string) { cssFile = f }(cssFile)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cssFile = test.cssFile
+			rules, err := stylesheet(m)
+			if test.err && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.err && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if len(rules) == 0 || !strings.HasPrefix(rules[len(rules)-1], test.last) {
+				t.Errorf("expected the last rule to start with %s, got %q", test.last, rules)
+			}
+			for _, rule := range rules {
+				if strings.Contains(rule, ":where(") || strings.Contains(rule, "@import") {
+					t.Errorf("expected plain class selectors, got %s", rule)
+				}
+			}
+			if !contains(rules, ".wm-background { fill:"+m.Theme.Background+" }") {
+				t.Errorf("expected the theme background rule, got %q", rules)
+			}
+			if importRule() != test.imports {
+				t.Errorf("expected import rule %q, got %q", test.imports, importRule())
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// crumb - Parent map in the breadcrumb of a submap.
+type crumb struct {
+	title string
+	// svg - Absolute path of the rendered map.
+	svg string
+}
+
+// page - Location of a map in the tree of submaps.
+type page struct {
+	// file - Absolute path of the map input file.
+	file string
+	// output - Absolute path of the rendered map.
+	output string
+	// named - The output file was given by the user instead of derived from the input file.
+	named bool
+	// trail - Parent maps, root first.
+	trail []crumb
+	// html - Links point to the html pages instead of the svg files.
+	html bool
+}
+
+// link - Returns the link from the page to the rendered map at target.
+func (pg page) link(target string) string {
+	if pg.html {
+		target = strings.TrimSuffix(target, filepath.Ext(target)) + ".html"
+	}
+	return relativeLink(pg.output, target)
+}
+
+// svgName - Returns the default output file for the input file.
+func svgName(inputFile string) string {
+	return strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + ".svg"
+}
+
+// mapTitle - Returns the meta title or the file name without extension.
+func mapTitle(file string, m *hcl.Map) string {
+	if m.Meta != nil && m.Meta.Title != "" {
+		return m.Meta.Title
+	}
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// submapFile - Returns the absolute path of the node submap.
+func submapFile(parent string, n *hcl.Node) string {
+	if filepath.IsAbs(n.Submap) {
+		return n.Submap
+	}
+	return filepath.Join(filepath.Dir(parent), n.Submap)
+}
+
+// relativeLink - Returns the link to target from a map rendered at svg.
+func relativeLink(svg, target string) string {
+	rel, err := filepath.Rel(filepath.Dir(svg), target)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+// submapLinks - Returns the node ID to submap link for the map page.
+func submapLinks(m *hcl.Map, pg page) map[string]string {
+	links := map[string]string{}
+	for _, n := range m.Nodes {
+		if n.Submap != "" {
+			links[n.ID] = pg.link(svgName(submapFile(pg.file, n)))
+		}
+	}
+	return links
+}
+
+// errStopWalk - Stops walkMaps without reporting an error.
+var errStopWalk = errors.New("stop walk")
+
+// walkMaps - Parses the map file and calls fn for it and for each of its submaps, depth first.
+// The output defaults to the input file with an svg extension.
+// Maps already visited are skipped so submap cycles terminate.
+func walkMaps(file, output string, trail []crumb, visited map[string]bool, fn func(m *hcl.Map, pg page) error) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path from '%s': %w", file, err)
+	}
+	if visited[file] {
+		return nil
+	}
+	visited[file] = true
+	m, err := parseInputFile(file)
+	if err != nil {
+		return err
+	}
+	named := output != ""
+	if !named {
+		output = svgName(file)
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path from '%s': %w", output, err)
+	}
+	err = fn(m, page{file: file, output: output, named: named, trail: trail})
+	if err != nil {
+		return err
+	}
+	trail = append(append([]crumb{}, trail...), crumb{title: mapTitle(file, m), svg: output})
+	for _, n := range m.Nodes {
+		if n.Submap == "" {
+			continue
+		}
+		err = walkMaps(submapFile(file, n), "", trail, visited, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// submapGlyph - Draws the submap glyph, a small square inside the node circle.
+func submapGlyph(x, y int, color string) {
+	canvas.Rect(x-2, y-2, 4, 4, styleAttrs("wm-node__submap", "fill:"+color, "")...)
+}
+
+// breadcrumb - Draws the links back to the parent maps above the map.
+func breadcrumb(m *hcl.Map, pg page) {
+	if len(pg.trail) == 0 {
+		return
+	}
+	fonts, theme, margin := m.Font, m.Theme, m.Size.Margin
+	title := mapTitle(pg.file, m)
+	x, y := margin*2, margin/2
+	style := fmt.Sprintf("font-size:%dpx;fill:%s", fonts.Size, theme.Muted)
+	width := func(s string) int {
+		b := textBox(0, 0, []string{s}, &hcl.Font{Size: fonts.Size}, "start")
+		return b.x1 - b.x0
+	}
+	canvas.Group(styleAttrs("wm-breadcrumb", "", "")...)
+	for _, c := range pg.trail {
+		link(canvas, pg.link(c.svg), c.title)
+		canvas.Text(x, y, c.title, styleAttrs("wm-breadcrumb__link", style, "text-anchor:start")...)
+		canvas.LinkEnd()
+		x += width(c.title) + 4
+		canvas.Text(x, y, "›", styleAttrs("wm-breadcrumb__separator", style, "text-anchor:start")...)
+		x += width("›") + 4
+	}
+	canvas.Text(x, y, title, styleAttrs("wm-breadcrumb__current", style, "text-anchor:start")...)
+	canvas.Gend()
+}
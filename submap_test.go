@@ -0,0 +1,148 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// submapNode - Returns a node block linking to the given submap.
+func submapNode(id, submap string) string {
+	return fmt.Sprintf(`node %s {
+	label      = "%s"
+	submap     = "%s"
+	visibility = 1
+	evolution  = "custom"
+	x          = 1
+}
+`, id, id, submap)
+}
+
+func TestWalkMaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// expected - Visited maps and their breadcrumb titles.
+		expected []string
+	}{
+		{"no submaps", map[string]string{
+			"root.hcl": `meta { title = "Root" }`,
+		}, []string{"root.hcl: "}},
+		{"nested", map[string]string{
+			"root.hcl":         `meta { title = "Root" }` + "\n" + submapNode("a", "a/child.hcl"),
+			"a/child.hcl":      submapNode("b", "../b/grandchild.hcl"),
+			"b/grandchild.hcl": `meta { title = "Grandchild" }`,
+		}, []string{"root.hcl: ", "a/child.hcl: Root", "b/grandchild.hcl: Root > child"}},
+		{"cycle", map[string]string{
+			"root.hcl":  `meta { title = "Root" }` + "\n" + submapNode("a", "child.hcl"),
+			"child.hcl": submapNode("b", "root.hcl"),
+		}, []string{"root.hcl: ", "child.hcl: Root"}},
+		{"self reference", map[string]string{
+			"root.hcl": submapNode("a", "root.hcl"),
+		}, []string{"root.hcl: "}},
+		{"shared submap", map[string]string{
+			"root.hcl":   submapNode("a", "a.hcl") + submapNode("b", "b.hcl"),
+			"a.hcl":      submapNode("c", "shared.hcl"),
+			"b.hcl":      submapNode("c", "shared.hcl"),
+			"shared.hcl": "",
+		}, []string{"root.hcl: ", "a.hcl: root", "shared.hcl: root > a", "b.hcl: root"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range test.files {
+				file := filepath.Join(dir, name)
+				err := os.MkdirAll(filepath.Dir(file), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = ioutil.WriteFile(file, []byte(data), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			visited := []string{}
+			err := walkMaps(filepath.Join(dir, "root.hcl"), "", nil, map[string]bool{}, func(m *hcl.Map, pg page) error {
+				rel, err := filepath.Rel(dir, pg.file)
+				if err != nil {
+					return err
+				}
+				if pg.output != svgName(pg.file) {
+					t.Errorf("expected the output next to the input file, got %s", pg.output)
+				}
+				titles := []string{}
+				for _, c := range pg.trail {
+					titles = append(titles, c.title)
+				}
+				visited = append(visited, filepath.ToSlash(rel)+": "+strings.Join(titles, " > "))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(visited, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, visited)
+			}
+		})
+	}
+}
+
+func TestWalkMapsStop(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.hcl")
+	err := ioutil.WriteFile(root, []byte(submapNode("a", "child.hcl")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "child.hcl"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	err = walkMaps(root, "", nil, map[string]bool{}, func(m *hcl.Map, pg page) error {
+		calls++
+		return errStopWalk
+	})
+	if err != errStopWalk || calls != 1 {
+		t.Errorf("expected the walk to stop after the first map, got %d calls and error %v", calls, err)
+	}
+}
+
+func TestBreadcrumb(t *testing.T) {
+	m, pg := testMap(t, "child", `meta { title = "Child" }`)
+	dir := filepath.Dir(pg.output)
+	pg.trail = []crumb{
+		{title: "Root & co", svg: filepath.Join(filepath.Dir(dir), "root.svg")},
+		{title: "Parent", svg: filepath.Join(dir, "parent.svg")},
+	}
+	buf := new(bytes.Buffer)
+	drawing(buf, m, pg)
+	out := buf.String()
+	for _, expected := range []string{
+		`<a xlink:href="../root.svg" xlink:title="Root &amp; co">`,
+		`<a xlink:href="parent.svg" xlink:title="Parent">`,
+		`>Child</text>`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %s in output:\n%s", expected, out)
+		}
+	}
+	if strings.Index(out, "../root.svg") > strings.Index(out, "parent.svg") {
+		t.Errorf("expected the root map first in the breadcrumb")
+	}
+}
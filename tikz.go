@@ -0,0 +1,370 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+// tikzEscape - Escapes the LaTeX special characters in text.
+var tikzEscape = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+	`<`, `\textless{}`,
+	`>`, `\textgreater{}`,
+	// A blank line ends the paragraph and breaks the node
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
+
+// tikzComment - Keeps text in a single comment line.
+var tikzComment = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// tikzAnchors - Node anchor for each svg text anchor, texts are placed by their baseline like in svg.
+var tikzAnchors = map[string]string{
+	"start":  "base west",
+	"middle": "base",
+	"end":    "base east",
+}
+
+// tikzBuilder - Collects the picture commands and the colours they use.
+type tikzBuilder struct {
+	mapFrame
+	body   bytes.Buffer
+	colors map[string]string
+	defs   []string
+	// height - Drawing height, TikZ y grows upwards.
+	height int
+}
+
+// printf - Adds a command to the picture.
+func (t *tikzBuilder) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&t.body, "  "+format+"\n", a...)
+}
+
+// color - Returns the name of the colour defined for the css colour, none, or an empty string when it can't be converted.
+// Hex colours and the basic css colour names are supported, see colorHex.
+func (t *tikzBuilder) color(css string) string {
+	if css == "none" || css == "transparent" {
+		return "none"
+	}
+	if name, ok := t.colors[css]; ok {
+		return name
+	}
+	name := ""
+	if hex := colorHex(css); hex != "" {
+		// xcolor reads - as the complement in colour expressions
+		name = fmt.Sprintf("wmcolor%d", len(t.defs)+1)
+		t.defs = append(t.defs, fmt.Sprintf("\\definecolor{%s}{HTML}{%s}", name, hex))
+	}
+	t.colors[css] = name
+	return name
+}
+
+// tikzColor - Returns the css colour, or the theme colour when it can't be converted.
+func tikzColor(css, theme string) string {
+	if css == "none" || css == "transparent" || colorHex(css) != "" {
+		return css
+	}
+	return theme
+}
+
+// options - Returns the option list from key and value pairs.
+// The draw, fill and text values are css colours, the options are skipped when the colour can't be converted.
+func (t *tikzBuilder) options(opts ...string) string {
+	list := []string{}
+	for i := 0; i+1 < len(opts); i += 2 {
+		key, value := opts[i], opts[i+1]
+		switch key {
+		case "draw", "fill", "text":
+			value = t.color(value)
+			if value == "" {
+				continue
+			}
+		}
+		if value == "" {
+			list = append(list, key)
+			continue
+		}
+		list = append(list, key+"="+value)
+	}
+	return strings.Join(list, ", ")
+}
+
+// point - Returns the TikZ coordinate of the point in drawing coordinates.
+func (t *tikzBuilder) point(x, y float64) string {
+	f := func(v float64) string { return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) }
+	return "(" + f(x) + "," + f(float64(t.height)-y) + ")"
+}
+
+// coord - Returns the TikZ coordinate of the point in map coordinates.
+func (t *tikzBuilder) coord(x, y int) string {
+	p := t.mapPoint(x, y)
+	return t.point(float64(p.X), float64(p.Y))
+}
+
+// polygon - Returns the closed path through the points in map coordinates.
+func (t *tikzBuilder) polygon(points []hcl.Point) string {
+	path := []string{}
+	for _, p := range points {
+		path = append(path, t.coord(p.X, p.Y))
+	}
+	return strings.Join(path, " -- ") + " -- cycle"
+}
+
+// rect - Returns the rectangle path of the box in map coordinates.
+func (t *tikzBuilder) rect(b box) string {
+	return t.coord(b.x0, b.y0) + " rectangle " + t.coord(b.x1, b.y1)
+}
+
+// text - Adds multi line text with its first baseline at x, y in drawing coordinates.
+// Only the size and weight of the font are kept, the family is the document one.
+func (t *tikzBuilder) text(x, y int, lines []string, font *hcl.Font, color, anchor string) {
+	for _, line := range lines {
+		t.printf(`\node[%s] at %s {%s};`, t.textOptions(font, color, anchor, 0), t.point(float64(x), float64(y)), tikzEscape.Replace(line))
+		y += font.Size + 3
+	}
+}
+
+// textOptions - Returns the options of a text node, rotated counterclockwise by rotate degrees.
+func (t *tikzBuilder) textOptions(font *hcl.Font, color, anchor string, rotate int) string {
+	f := fmt.Sprintf(`\fontsize{%dbp}{%dbp}\selectfont`, font.Size, font.Size+3)
+	if font.Weight == "bold" {
+		f += `\bfseries`
+	}
+	opts := t.options("anchor", tikzAnchors[anchor], "inner sep", "0", "outer sep", "0", "text", color, "font", f)
+	if rotate != 0 {
+		opts += fmt.Sprintf(", rotate=%d", rotate)
+	}
+	return opts
+}
+
+// tikzExport - Writes the rendered map as a TikZ picture to include in LaTeX documents.
+// Axes, stage separators, regions, nodes, labels and connectors are positioned as in the svg drawing.
+// Texts use the document fonts and the legend and breadcrumb are left out.
+func tikzExport(w io.Writer, m *hcl.Map, pg page) error {
+	// The layout is computed while drawing
+	drawing(ioutil.Discard, m, pg)
+
+	t := &tikzBuilder{
+		mapFrame: newMapFrame(m.Size),
+		colors:   map[string]string{},
+		height:   m.Size.Height,
+	}
+	if c := t.color(m.Theme.Background); c != "" && c != "none" {
+		t.printf(`\fill[%s] (0,0) rectangle (%d,%d);`, c, m.Size.Width, m.Size.Height)
+	}
+	tikzGrid(t, m)
+	tikzRegions(t, m)
+	for _, c := range m.Connectors {
+		if hasRoute(c) {
+			tikzConnector(t, m, c)
+		}
+	}
+	for _, n := range m.Nodes {
+		if n.Inertia != nil {
+			tikzInertia(t, m, n)
+		}
+	}
+	for _, n := range m.Nodes {
+		tikzNode(t, m, n)
+	}
+	tikzAccelerators(t, m)
+
+	fmt.Fprintf(w, "%% %s\n", tikzComment.Replace(mapTitle(pg.file, m)))
+	fmt.Fprintln(w, "% Generated by go-wardley, include it with \\input.")
+	fmt.Fprintln(w, "% Requires \\usepackage{tikz} and \\usetikzlibrary{arrows.meta}.")
+	fmt.Fprintln(w, `\begin{tikzpicture}[x=1bp, y=1bp, line width=1bp]`)
+	for _, def := range t.defs {
+		fmt.Fprintf(w, "  %s\n", def)
+	}
+	_, err := t.body.WriteTo(w)
+	if err != nil {
+		return fmt.Errorf("failed to write map: %w", err)
+	}
+	fmt.Fprintln(w, `\end{tikzpicture}`)
+	return nil
+}
+
+// tikzGrid - Adds the axes, stage separators, axis texts and the map header, see grid and header.
+func tikzGrid(t *tikzBuilder, m *hcl.Map) {
+	margin, width, height := m.Size.Margin, m.Size.Width, m.Size.Height
+	xZero, xEnd := margin*2, width-margin*2
+	yZero, yEnd := height-margin*2, margin*2
+	yLength := height - margin*4
+	theme, fonts, axes := m.Theme, m.Font, m.Axes
+	pt := func(x, y int) string { return t.point(float64(x), float64(y)) }
+
+	// The svg arrow head starts at the line end, TikZ arrow heads end there
+	axis := t.options("-{Triangle[length=12bp, width=6bp]}", "", "draw", theme.Axis)
+	t.printf(`\draw[%s] %s -- %s;`, axis, pt(xZero, yZero), pt(xEnd+12, yZero))
+	t.printf(`\draw[%s] %s -- %s;`, axis, pt(xZero, yZero), pt(xZero, yEnd-12))
+	for _, x := range mapGrid.Stages[1:] {
+		t.printf(`\draw[%s] %s -- %s;`, t.options("draw", theme.Grid, "dash pattern", "on 1bp off 10bp"), pt(xZero+x, yZero), pt(xZero+x, yEnd))
+	}
+	for i, x := range mapGrid.Stages {
+		t.text(xZero+x, height-margin, []string{axes.Stages[i]}, fonts.Axis, theme.Text, "start")
+	}
+	t.text(xEnd, height-2*margin-5, []string{axes.Evolution}, fonts.AxisTitle, theme.Text, "end")
+	// Drawn at u, v in a frame rotated by 270 degrees around the origin
+	rotated := func(text string, font *hcl.Font, u, v int, anchor string) {
+		t.printf(`\node[%s] at %s {%s};`, t.textOptions(font, theme.Text, anchor, 90), pt(xZero+v, yZero-u), tikzEscape.Replace(text))
+	}
+	rotated(axes.Invisible, fonts.Axis, 0, -5, "start")
+	rotated(axes.Visible, fonts.Axis, yLength, -5, "end")
+	rotated(axes.ValueChain, fonts.AxisTitle, yLength, fonts.AxisTitle.Size+5, "end")
+
+	if m.Meta == nil {
+		return
+	}
+	muted := &hcl.Font{Family: fonts.Family, Size: fonts.Size, Weight: fonts.Weight}
+	t.text(xZero, margin, []string{m.Meta.Title}, fonts.Title, theme.Text, "start")
+	if m.Meta.Subtitle != "" {
+		t.text(xZero, margin+fonts.Size+4, []string{m.Meta.Subtitle}, muted, theme.Muted, "start")
+	}
+	if details := metaDetails(m.Meta); details != "" {
+		t.text(xEnd, margin, []string{details}, muted, theme.Muted, "end")
+	}
+	if m.Meta.Source != "" {
+		t.text(xEnd, margin+fonts.Size+4, []string{m.Meta.Source}, muted, theme.Muted, "end")
+	}
+}
+
+// tikzRegions - Adds the regions as filled polygons.
+func tikzRegions(t *tikzBuilder, m *hcl.Map) {
+	for _, a := range regionAreas(m) {
+		r := a.region
+		t.printf(`\fill[%s] %s;`, t.options("fill", tikzColor(r.Fill, m.Theme.Muted), "opacity", strconv.FormatFloat(r.Opacity, 'f', -1, 64)), t.polygon(a.points))
+		if r.Label != "" {
+			t.text(a.labelX+t.dx, a.labelY+t.dy, strings.Split(r.Label, "\n"), m.Font.Node, m.Theme.Text, "start")
+		}
+	}
+}
+
+// tikzConnector - Adds the connector with the svg connector style, curves are drawn as Bézier curves.
+func tikzConnector(t *tikzBuilder, m *hcl.Map, c *hcl.Connector) {
+	opts := []string{"draw", tikzColor(c.Color, m.Theme.ConnectorColor)}
+	switch c.Type {
+	case "normal":
+		opts = append(opts, "opacity", "0.2")
+	case "bold":
+		opts = append(opts, "opacity", "0.8")
+	default:
+		// The svg arrow head ends 5px before the node centre, see connector-arrow
+		opts = append(opts, "opacity", "0.6", "dash pattern", "on 6bp off 6bp",
+			"-{Triangle[length=12bp, width=6bp]}", "", "shorten >", "5bp")
+	}
+	path := ""
+	if c.Shape == "curved" && len(c.Route) == 3 {
+		// The quadratic curve as a cubic one
+		a, control, b := c.Route[0], c.Route[1], c.Route[2]
+		c1x, c1y := float64(a.X)+2*float64(control.X-a.X)/3, float64(a.Y)+2*float64(control.Y-a.Y)/3
+		c2x, c2y := float64(b.X)+2*float64(control.X-b.X)/3, float64(b.Y)+2*float64(control.Y-b.Y)/3
+		path = fmt.Sprintf("%s .. controls %s and %s .. %s", t.coord(a.X, a.Y),
+			t.point(c1x+float64(t.dx), c1y+float64(t.dy)), t.point(c2x+float64(t.dx), c2y+float64(t.dy)), t.coord(b.X, b.Y))
+	} else {
+		points := []string{}
+		for _, p := range c.Route {
+			points = append(points, t.coord(p.X, p.Y))
+		}
+		path = strings.Join(points, " -- ")
+	}
+	t.printf(`\draw[%s] %s;`, t.options(opts...), path)
+	if c.Type == "change-inertia" {
+		bar, angle := connectorInertiaBar(c)
+		opts := []string{"fill", m.Theme.ConnectorColor, "opacity", "0.6"}
+		// TikZ angles are counterclockwise
+		if degrees := math.Round(-angle*180/math.Pi*100) / 100; degrees != 0 {
+			center := t.coord((bar.x0+bar.x1)/2, (bar.y0+bar.y1)/2)
+			opts = append(opts, "rotate around", fmt.Sprintf("{%s:%s}", strconv.FormatFloat(degrees, 'f', -1, 64), center))
+		}
+		t.printf(`\fill[%s] %s;`, t.options(opts...), t.rect(bar))
+	}
+	if c.Label != "" {
+		t.text(c.LabelX+t.dx, c.LabelY+t.dy, strings.Split(c.Label, "\n"), m.Font.Connector, m.Theme.Text, c.LabelAnchor)
+	}
+}
+
+// tikzNode - Adds the node glyph and its label, anchors are only bold text.
+func tikzNode(t *tikzBuilder, m *hcl.Map, n *hcl.Node) {
+	lines := strings.Split(n.Label, "\n")
+	if n.IsAnchor() {
+		bold := *m.Font.Node
+		bold.Weight = "bold"
+		t.text(n.LabelX+t.dx, n.LabelY+t.dy, lines, &bold, m.Theme.Text, n.LabelAnchor)
+		return
+	}
+	center := t.coord(n.X, n.Y)
+	fill, color := tikzColor(n.Fill, m.Theme.NodeFill), tikzColor(n.Color, m.Theme.NodeColor)
+	if n.Method != "" {
+		methodStroke, methodFill := styleColors(methodStyles[n.Method])
+		t.printf(`\filldraw[%s] %s circle (%d);`, t.options("fill", methodFill, "draw", methodStroke), center, methodRadius)
+	}
+	r := glyphRadius(n.Type)
+	t.printf(`\filldraw[%s] %s circle (%d);`, t.options("fill", fill, "draw", color), center, r)
+	switch n.Type {
+	case "market":
+		points := marketPoints(n.X, n.Y, r)
+		t.printf(`\draw[%s] %s;`, t.options("draw", color), t.polygon(points))
+		for _, p := range points {
+			t.printf(`\fill[%s] %s circle (2);`, t.options("fill", color), t.coord(p.X, p.Y))
+		}
+	case "ecosystem":
+		t.printf(`\draw[%s] %s circle (%d);`, t.options("draw", color), center, r*6/10)
+		t.printf(`\fill[%s] %s circle (%d);`, t.options("fill", color), center, r*2/10)
+	}
+	if n.Submap != "" {
+		t.printf(`\fill[%s] %s;`, t.options("fill", color), t.rect(circleBox(n.X, n.Y, 2)))
+	}
+	t.text(n.LabelX+t.dx, n.LabelY+t.dy, lines, m.Font.Node, m.Theme.Text, n.LabelAnchor)
+}
+
+// tikzInertia - Adds the node inertia bar and its label.
+func tikzInertia(t *tikzBuilder, m *hcl.Map, n *hcl.Node) {
+	t.printf(`\fill[%s] %s;`, t.options("fill", m.Theme.ConnectorColor, "opacity", "0.6"), t.rect(inertiaBar(n)))
+	if n.Inertia.Label != "" {
+		x, y, lines := inertiaLabel(n, m.Font.Node)
+		t.text(x+t.dx, y+t.dy, lines, m.Font.Node, m.Theme.Text, "middle")
+	}
+}
+
+// tikzAccelerators - Adds the accelerator arrows and their labels, the arrow heads are polygons.
+func tikzAccelerators(t *tikzBuilder, m *hcl.Map) {
+	fill := t.options("fill", m.Theme.ConnectorColor, "opacity", "0.6")
+	for _, a := range m.Accelerators {
+		arrow := acceleratorShape(a)
+		t.printf(`\draw[%s] %s -- %s;`, t.options("draw", m.Theme.ConnectorColor, "line width", "10bp", "opacity", "0.6"),
+			t.coord(arrow.x0, a.Y), t.coord(arrow.head[0].X, a.Y))
+		t.printf(`\fill[%s] %s;`, fill, t.polygon(arrow.head))
+		if arrow.bar != nil {
+			t.printf(`\fill[%s] %s;`, fill, t.rect(*arrow.bar))
+		}
+		if a.Label != "" {
+			x, y, lines := acceleratorLabel(a, m.Font.Node)
+			t.text(x+t.dx, y+t.dy, lines, m.Font.Node, m.Theme.Text, "middle")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// This file is part of go-wardley.
+//
+// Copyright (C) 2019-2020  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-wardley/hcl"
+)
+
+var tikzColorRe = regexp.MustCompile(`(?:draw|fill|text)=(\w+)`)
+
+// tikzLines - Returns the picture commands, checking the picture structure.
+func tikzLines(t *testing.T, m *hcl.Map, pg page) []string {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	err := tikzExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	begin := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, `\begin{tikzpicture}`) {
+			begin = i
+			break
+		}
+		if !strings.HasPrefix(line, "%") {
+			t.Errorf("expected only comments before the picture, got %q", line)
+		}
+	}
+	if begin == -1 || lines[len(lines)-1] != `\end{tikzpicture}` {
+		t.Fatalf("expected a tikzpicture:\n%s", buf.String())
+	}
+	body := lines[begin+1 : len(lines)-1]
+	defined := map[string]bool{}
+	for _, line := range body {
+		if !strings.HasPrefix(line, `  \`) || !strings.HasSuffix(line, "}") && !strings.HasSuffix(line, ";") {
+			t.Errorf("unexpected command %q", line)
+		}
+		// Escaped braces are text
+		unescaped := strings.NewReplacer(`\{`, "", `\}`, "").Replace(line)
+		if strings.Count(unescaped, "{") != strings.Count(unescaped, "}") {
+			t.Errorf("unbalanced braces in %q", line)
+		}
+		if strings.HasPrefix(line, `  \definecolor{`) {
+			defined[strings.SplitN(line[len(`  \definecolor{`):], "}", 2)[0]] = true
+			continue
+		}
+		for _, match := range tikzColorRe.FindAllStringSubmatch(line, -1) {
+			if match[1] != "none" && !defined[match[1]] {
+				t.Errorf("colour %s is used before it is defined in %q", match[1], line)
+			}
+		}
+	}
+	return body
+}
+
+// tikzContains - Reports whether a command contains all the parts.
+func tikzContains(lines []string, parts ...string) bool {
+	for _, line := range lines {
+		found := true
+		for _, p := range parts {
+			found = found && strings.Contains(line, p)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTikzExport(t *testing.T) {
+	m, pg := testMap(t, "features", featureMap)
+	lines := tikzLines(t, m, pg)
+	for _, parts := range [][]string{
+		{`\node[`, `{Features \& \textless{}tests\textgreater{}};`},
+		{`\node[`, `{Author · 2020-03-01 · 1.2};`},
+		{`\node[`, `{Web\_\%site};`},
+		{`\node[`, `{Eco};`},
+		{`\node[`, `{system};`},
+		{`\node[`, `{Legacy};`},
+		{`\node[`, `{Patents};`},
+		{`\filldraw[`, `circle (15);`},
+		{`\draw[`, ` .. controls `},
+		{`\draw[`, `-{Triangle`, `shorten >=5bp`},
+		{`\draw[`, `line width=10bp`},
+		{`\fill[`, `rotate around=`},
+	} {
+		if !tikzContains(lines, parts...) {
+			t.Errorf("missing command with %q", parts)
+		}
+	}
+	// One filled rectangle for each inertia bar, connector inertia bar and de-accelerator bar
+	rects := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, `  \fill[`) && strings.Contains(line, " rectangle ") && !strings.Contains(line, "(0,0)") {
+			rects++
+		}
+	}
+	if rects != 3 {
+		t.Errorf("expected 3 bars, got %d", rects)
+	}
+}
+
+func TestTikzExportUnknownNode(t *testing.T) {
+	m, pg := testMap(t, "unknown", unknownNodeMap)
+	lines := tikzLines(t, m, pg)
+	if tikzContains(lines, `{lbl};`) {
+		t.Errorf("expected the connector to an unknown node to be skipped")
+	}
+	if !tikzContains(lines, `\draw[`, `opacity=0.2`) {
+		t.Errorf("expected the a to b connector")
+	}
+}
+
+func TestTikzExportTitle(t *testing.T) {
+	m, pg := testMap(t, "title", `meta {
+	title = "Multi\n\nline 100% {title}"
+}
+node a {
+	label      = "A"
+	visibility = 1
+	evolution  = "custom"
+	x          = 1
+}
+`)
+	lines := tikzLines(t, m, pg)
+	if !tikzContains(lines, `\node[`, `{Multi  line 100\% \{title\}};`) {
+		t.Errorf("expected the title on one escaped line:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestTikzExportColors(t *testing.T) {
+	m, pg := testMap(t, "colors", `node a {
+	label      = "A"
+	fill       = "rebeccapurple"
+	color      = "#00f"
+	visibility = 1
+	evolution  = "custom"
+	x          = 1
+}
+node b {
+	label      = "B"
+	fill       = "none"
+	color      = "hsl(0, 100%, 50%)"
+	visibility = 2
+	evolution  = "custom"
+	x          = 1
+}
+`)
+	buf := new(bytes.Buffer)
+	err := tikzExport(buf, m, pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	colors := map[string]string{}
+	for _, match := range regexp.MustCompile(`\\definecolor\{(\w+)\}\{HTML\}\{(\w+)\}`).FindAllStringSubmatch(out, -1) {
+		colors[match[2]] = match[1]
+	}
+	// Unknown colours fall back to the theme node fill and colour, white and black
+	lines := tikzLines(t, m, pg)
+	for _, parts := range [][]string{
+		{`\filldraw[fill=` + colors["FFFFFF"] + `, draw=` + colors["0000FF"] + `]`, `circle (5);`},
+		{`\filldraw[fill=none, draw=` + colors["000000"] + `]`, `circle (5);`},
+	} {
+		if !tikzContains(lines, parts...) {
+			t.Errorf("missing command with %q:\n%s", parts, out)
+		}
+	}
+}